@@ -0,0 +1,162 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// assetsDir is the directory under the local root in which the images of entries
+// are stored by pull --assets.
+const assetsDir = "assets"
+
+// fotolifeImageBase is the base URL of the images uploaded to Hatena Fotolife
+const fotolifeImageBase = "https://cdn-ak.f.st-hatena.com/images/fotolife/"
+
+var (
+	fotolifeSyntaxReg = regexp.MustCompile(`\[f:id:([-\w]+):(\d{14})([a-z]?)(?::[^\]]*)?\]`)
+	htmlImageReg      = regexp.MustCompile(`<img\b[^>]*?\bsrc=["'](https?://[^"']+)["']`)
+)
+
+// imageRef is a remote image referenced in the content of an entry.
+type imageRef struct {
+	URL string
+	// Syntax is the Hatena syntax of the image if it is referenced by one
+	Syntax string
+}
+
+// fotolifeImageURL returns the URL of the image of Hatena Fotolife with the type
+// such as "p" for PNG, which follows the id in the syntax.
+func fotolifeImageURL(user, id, typ string) string {
+	ext := "jpg"
+	switch typ {
+	case "p":
+		ext = "png"
+	case "g":
+		ext = "gif"
+	}
+	return fotolifeImageBase + user[:1] + "/" + user + "/" + id[:8] + "/" + id + "." + ext
+}
+
+// imageRefs returns the remote images referenced by the Hatena syntax, Markdown
+// or img tags in content without duplicates.
+func imageRefs(content string) []imageRef {
+	var refs []imageRef
+	seen := map[string]bool{}
+	add := func(r imageRef) {
+		if !seen[r.URL] {
+			seen[r.URL] = true
+			refs = append(refs, r)
+		}
+	}
+	for _, m := range fotolifeSyntaxReg.FindAllStringSubmatch(content, -1) {
+		add(imageRef{
+			URL:    fotolifeImageURL(m[1], m[2], m[3]),
+			Syntax: "f:id:" + m[1] + ":" + m[2] + m[3] + ":image",
+		})
+	}
+	for _, m := range markdownImageReg.FindAllStringSubmatch(content, -1) {
+		if u, err := url.Parse(m[2]); err == nil && (u.Scheme == "http" || u.Scheme == "https") {
+			add(imageRef{URL: m[2]})
+		}
+	}
+	for _, m := range htmlImageReg.FindAllStringSubmatch(content, -1) {
+		add(imageRef{URL: m[1]})
+	}
+	return refs
+}
+
+// assetPath returns the path to store the image at rawURL, which is
+// assets/<host>/<path> under the local root.
+func (b *broker) assetPath(rawURL string) (string, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", err
+	}
+	p := path.Clean("/" + u.Path)
+	if u.Host == "" || p == "/" {
+		return "", fmt.Errorf("unsupported image URL: %s", rawURL)
+	}
+	return filepath.Join(b.localRoot(), assetsDir, u.Host, filepath.FromSlash(p)), nil
+}
+
+// pullAssets downloads the images referenced in re if pull --assets is given.
+// The images are recorded with the remote ones, so that the local paths to them
+// are rewritten back to the remote ones instead of uploading on push.
+func (b *broker) pullAssets(ctx context.Context, re *entry) error {
+	if !b.withAssets {
+		return nil
+	}
+	for _, ref := range imageRefs(re.Content) {
+		if err := b.pullAsset(ctx, ref); err != nil {
+			b.logf("warn", "failed to download %s: %s", ref.URL, err)
+		}
+	}
+	return nil
+}
+
+func (b *broker) pullAsset(ctx context.Context, ref imageRef) error {
+	p, err := b.assetPath(ref.URL)
+	if err != nil {
+		return err
+	}
+	data, err := os.ReadFile(p)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			return err
+		}
+		if dryRun {
+			b.plan("GET %s", ref.URL)
+			return b.writeFile(p, nil)
+		}
+		if data, err = b.download(ctx, ref.URL); err != nil {
+			return err
+		}
+		if err := b.writeFile(p, data); err != nil {
+			return err
+		}
+		b.logf("download", "%s -> %s", ref.URL, p)
+	}
+	hash := contentHash(data)
+	is := b.syncState().image(hash)
+	if is != nil && is.Path != "" {
+		return nil
+	}
+	ns := &imageState{Syntax: ref.Syntax, URL: ref.URL, Path: b.relPath(p)}
+	if is != nil && ns.Syntax == "" {
+		ns.Syntax = is.Syntax
+	}
+	b.syncState().setImage(hash, ns)
+	return nil
+}
+
+// download gets the content at rawURL without the credentials for the API.
+func (b *broker) download(ctx context.Context, rawURL string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", rawURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := (&http.Client{Timeout: requestTimeout}).Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("got %s", resp.Status)
+	}
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if ct := http.DetectContentType(data); !strings.HasPrefix(ct, "image/") {
+		return nil, fmt.Errorf("not an image: %s", ct)
+	}
+	return data, nil
+}
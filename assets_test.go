@@ -0,0 +1,102 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/x-motemen/blogsync/atom"
+)
+
+func TestImageRefs(t *testing.T) {
+	content := `[f:id:motemen:20140101123456p:plain]
+[f:id:motemen:20140101123457:image:w300]
+![foo](https://example.com/foo.png "foo")
+![bar](./bar.png)
+<img src="https://example.com/baz.gif" alt="baz">
+![foo again](https://example.com/foo.png)
+`
+	expect := []imageRef{
+		{
+			URL:    "https://cdn-ak.f.st-hatena.com/images/fotolife/m/motemen/20140101/20140101123456.png",
+			Syntax: "f:id:motemen:20140101123456p:image",
+		},
+		{
+			URL:    "https://cdn-ak.f.st-hatena.com/images/fotolife/m/motemen/20140101/20140101123457.jpg",
+			Syntax: "f:id:motemen:20140101123457:image",
+		},
+		{URL: "https://example.com/foo.png"},
+		{URL: "https://example.com/baz.gif"},
+	}
+	if got := imageRefs(content); !reflect.DeepEqual(got, expect) {
+		t.Errorf("got:\n%+v\nwant:\n%+v", got, expect)
+	}
+}
+
+func TestPullAssets(t *testing.T) {
+	png := "\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR"
+	var hits int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		if r.URL.Path != "/img/foo.png" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(png))
+	}))
+	defer ts.Close()
+
+	s, dir := setupFakeBlog(t)
+	if err := appendFile(filepath.Join(dir, "blogsync.yaml"),
+		"  fotolife_endpoint: "+s.FotolifeEndpoint()+"\n"); err != nil {
+		t.Fatal(err)
+	}
+	content := "![foo](" + ts.URL + "/img/foo.png)\n![missing](" + ts.URL + "/img/missing.png)\n"
+	editURL := addFakeAtomEntry(t, s, &atom.Entry{
+		Title:     "assets",
+		Content:   atom.Content{Content: content},
+		CustomURL: "assets",
+	})
+
+	blogsync := blogsyncApp(newApp())
+	if _, err := blogsync("pull", "--assets"); err != nil {
+		t.Fatal(err)
+	}
+	host := strings.TrimPrefix(ts.URL, "http://")
+	assetFile := filepath.Join(dir, assetsDir, host, "img", "foo.png")
+	if bb, err := os.ReadFile(assetFile); err != nil || string(bb) != png {
+		t.Fatalf("image should be downloaded: %v", err)
+	}
+	if exists(filepath.Join(dir, assetsDir, host, "img", "missing.png")) {
+		t.Errorf("missing image should not be stored")
+	}
+
+	t.Log("downloaded images are not downloaded again")
+	atomic.StoreInt32(&hits, 0)
+	if _, err := blogsync("pull", "--assets", "--full"); err != nil {
+		t.Fatal(err)
+	}
+	if n := atomic.LoadInt32(&hits); n != 1 {
+		t.Errorf("only the missing image should be requested: %d", n)
+	}
+
+	t.Log("local paths to downloaded images are rewritten to the remote ones on push")
+	entryFile := filepath.Join(dir, "entry", "assets.md")
+	if err := appendFile(entryFile, "![local](../"+assetsDir+"/"+host+"/img/foo.png)\n"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := blogsync("push", entryFile); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(s.Images()); n != 0 {
+		t.Errorf("downloaded images should not be uploaded: %d", n)
+	}
+	if g, expect := s.Entry(editURL).Content.Content, content+"![local]("+ts.URL+"/img/foo.png)\n"; g != expect {
+		t.Errorf("got:\n%s\nwant:\n%s", g, expect)
+	}
+}
@@ -28,6 +28,8 @@ type Entry struct {
 	Category  []Category `xml:"category,omitempty"`
 	Control   *Control   `xml:"http://www.w3.org/2007/app control,omitempty"`
 	CustomURL string     `xml:"http://www.hatena.ne.jp/info/xmlns#hatenablog custom-url,omitempty"`
+	// FormattedContent is the HTML rendered by the server, which is read only
+	FormattedContent *Content `xml:"http://www.hatena.ne.jp/info/xmlns# formatted-content,omitempty"`
 }
 
 // Link represents atom link
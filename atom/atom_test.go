@@ -2,6 +2,7 @@ package atom
 
 import (
 	"os"
+	"strings"
 	"testing"
 )
 
@@ -21,4 +22,9 @@ func TestParse(t *testing.T) {
 	if g := feed.Entries[0].Edited.UTC().String(); g != expect {
 		t.Errorf("expect: %s, but got: %s", expect, g)
 	}
+
+	fc := feed.Entries[0].FormattedContent
+	if fc == nil || fc.Type != "text/html" || !strings.HasPrefix(fc.Content, "<p>") {
+		t.Errorf("unexpected formatted content: %#v", fc)
+	}
 }
@@ -2,8 +2,8 @@ package atom
 
 import (
 	"bytes"
+	"context"
 	"encoding/xml"
-	"fmt"
 	"io"
 	"log"
 	"log/slog"
@@ -12,16 +12,37 @@ import (
 	"path/filepath"
 	"strings"
 	"sync"
+	"time"
 )
 
 // Client wrapped *http.Client and some methods for accessing atom feed are added
 type Client struct {
 	*http.Client
+
+	// MaxRetries is the max number of retries of idempotent requests (GET, PUT
+	// and DELETE) on network errors, 5xx and 429 responses. 0 means no retries.
+	MaxRetries int
+	// RetryWaitMin and RetryWaitMax are the bounds of the exponential backoff
+	// between retries. They default to 1 second and 30 seconds.
+	RetryWaitMin time.Duration
+	RetryWaitMax time.Duration
+	// RateLimit is the max number of requests per second. 0 means unlimited.
+	RateLimit float64
+	// Limiter paces the requests instead of RateLimit if set, which is shared
+	// with other clients to limit the requests of them in total.
+	Limiter *Limiter
+
+	limiterOnce sync.Once
 }
 
 // GetFeed gets the blog feed
 func (c *Client) GetFeed(url string) (*Feed, error) {
-	resp, err := c.http("GET", url, nil)
+	return c.GetFeedContext(context.Background(), url)
+}
+
+// GetFeedContext is like GetFeed but with a context
+func (c *Client) GetFeedContext(ctx context.Context, url string) (*Feed, error) {
+	resp, err := c.http(ctx, "GET", url, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -31,7 +52,12 @@ func (c *Client) GetFeed(url string) (*Feed, error) {
 
 // GetEntry gets the blog entry
 func (c *Client) GetEntry(url string) (*Entry, error) {
-	resp, err := c.http("GET", url, nil)
+	return c.GetEntryContext(context.Background(), url)
+}
+
+// GetEntryContext is like GetEntry but with a context
+func (c *Client) GetEntryContext(ctx context.Context, url string) (*Entry, error) {
+	resp, err := c.http(ctx, "GET", url, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -41,15 +67,17 @@ func (c *Client) GetEntry(url string) (*Entry, error) {
 
 // PutEntry puts the blog entry
 func (c *Client) PutEntry(url string, e *Entry) (*Entry, error) {
-	body := new(bytes.Buffer)
+	return c.PutEntryContext(context.Background(), url, e)
+}
 
-	body.WriteString(xml.Header)
-	err := xml.NewEncoder(body).Encode(e)
+// PutEntryContext is like PutEntry but with a context
+func (c *Client) PutEntryContext(ctx context.Context, url string, e *Entry) (*Entry, error) {
+	body, err := entryBody(e)
 	if err != nil {
 		return nil, err
 	}
 
-	resp, err := c.http("PUT", url, body)
+	resp, err := c.http(ctx, "PUT", url, body)
 	if err != nil {
 		return nil, err
 	}
@@ -64,12 +92,17 @@ func (c *Client) PutEntry(url string, e *Entry) (*Entry, error) {
 
 // PostEntry posts the blog entry
 func (c *Client) PostEntry(url string, e *Entry) (*Entry, error) {
+	return c.PostEntryContext(context.Background(), url, e)
+}
+
+// PostEntryContext is like PostEntry but with a context
+func (c *Client) PostEntryContext(ctx context.Context, url string, e *Entry) (*Entry, error) {
 	body, err := entryBody(e)
 	if err != nil {
 		return nil, err
 	}
 
-	resp, err := c.http("POST", url, body)
+	resp, err := c.http(ctx, "POST", url, body)
 	if err != nil {
 		return nil, err
 	}
@@ -96,7 +129,12 @@ func entryBody(e *Entry) (*bytes.Buffer, error) {
 
 // DeleteEntry removes the blog entry
 func (c *Client) DeleteEntry(url string) error {
-	_, err := c.http("DELETE", url, nil)
+	return c.DeleteEntryContext(context.Background(), url)
+}
+
+// DeleteEntryContext is like DeleteEntry but with a context
+func (c *Client) DeleteEntryContext(ctx context.Context, url string) error {
+	_, err := c.http(ctx, "DELETE", url, nil)
 	return err
 }
 
@@ -119,7 +157,7 @@ var debugLogger = sync.OnceValue(func() *slog.Logger {
 	}))
 })
 
-func (c *Client) http(method, url string, body io.Reader) (resp *http.Response, err error) {
+func (c *Client) http(ctx context.Context, method, url string, body io.Reader) (resp *http.Response, err error) {
 	if blogsyncDebug {
 		var reqBody string
 		if body != nil {
@@ -151,19 +189,20 @@ func (c *Client) http(method, url string, body io.Reader) (resp *http.Response,
 		}()
 	}
 
-	req, err := http.NewRequest(method, url, body)
-	if err != nil {
-		return nil, err
-	}
-
-	resp, err = c.Client.Do(req)
+	resp, err = c.doWithRetry(ctx, method, url, body)
 	if err != nil {
 		return nil, err
 	}
 
 	if resp.StatusCode >= 300 {
 		bytes, _ := io.ReadAll(resp.Body)
-		return resp, fmt.Errorf("got [%s]: %q", resp.Status, string(bytes))
+		return resp, &APIError{
+			StatusCode: resp.StatusCode,
+			Status:     resp.Status,
+			Method:     method,
+			URL:        url,
+			Body:       strings.TrimSpace(string(bytes)),
+		}
 	}
 
 	return resp, nil
@@ -0,0 +1,225 @@
+package atom
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestClientRetry(t *testing.T) {
+	testCases := []struct {
+		name       string
+		method     string
+		statuses   []int
+		retryAfter string
+		maxRetries int
+		expectErr  bool
+		expectReqs int32
+	}{
+		{
+			name:       "retry GET on 5xx",
+			method:     http.MethodGet,
+			statuses:   []int{500, 502, 200},
+			maxRetries: 3,
+			expectReqs: 3,
+		},
+		{
+			name:       "give up after max retries",
+			method:     http.MethodGet,
+			statuses:   []int{500, 500, 500},
+			maxRetries: 2,
+			expectErr:  true,
+			expectReqs: 3,
+		},
+		{
+			name:       "retry PUT on 429 with Retry-After",
+			method:     http.MethodPut,
+			statuses:   []int{429, 200},
+			retryAfter: "0",
+			maxRetries: 3,
+			expectReqs: 2,
+		},
+		{
+			name:       "do not retry POST",
+			method:     http.MethodPost,
+			statuses:   []int{503, 200},
+			maxRetries: 3,
+			expectErr:  true,
+			expectReqs: 1,
+		},
+		{
+			name:       "do not retry on 4xx",
+			method:     http.MethodDelete,
+			statuses:   []int{404, 200},
+			maxRetries: 3,
+			expectErr:  true,
+			expectReqs: 1,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var reqs int32
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				n := atomic.AddInt32(&reqs, 1)
+				status := tc.statuses[min(int(n), len(tc.statuses))-1]
+				if tc.retryAfter != "" {
+					w.Header().Set("Retry-After", tc.retryAfter)
+				}
+				w.WriteHeader(status)
+			}))
+			defer ts.Close()
+
+			c := &Client{
+				Client:       http.DefaultClient,
+				MaxRetries:   tc.maxRetries,
+				RetryWaitMin: time.Millisecond,
+				RetryWaitMax: 2 * time.Millisecond,
+			}
+			_, err := c.http(context.Background(), tc.method, ts.URL, nil)
+			if tc.expectErr && err == nil {
+				t.Errorf("error should be occurred")
+			}
+			if !tc.expectErr && err != nil {
+				t.Errorf("error should be nil but: %s", err)
+			}
+			if g := atomic.LoadInt32(&reqs); g != tc.expectReqs {
+				t.Errorf("requests: got %d, want %d", g, tc.expectReqs)
+			}
+		})
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	resp := &http.Response{StatusCode: http.StatusServiceUnavailable, Header: http.Header{}}
+	resp.Header.Set("Retry-After", "3")
+	if g := retryAfter(resp); g != 3*time.Second {
+		t.Errorf("got %s, want 3s", g)
+	}
+	resp.Header.Set("Retry-After", time.Now().Add(time.Minute).UTC().Format(http.TimeFormat))
+	if g := retryAfter(resp); g <= 50*time.Second || g > time.Minute {
+		t.Errorf("got %s, want about 1m", g)
+	}
+	resp.StatusCode = http.StatusInternalServerError
+	if g := retryAfter(resp); g != 0 {
+		t.Errorf("Retry-After should be ignored for 500 but got %s", g)
+	}
+}
+
+func TestClientRateLimit(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer ts.Close()
+
+	c := &Client{Client: http.DefaultClient, RateLimit: 50}
+	start := time.Now()
+	for i := 0; i < 5; i++ {
+		if _, err := c.http(context.Background(), http.MethodGet, ts.URL, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// the first request is sent immediately and the rest wait 20ms each
+	if elapsed := time.Since(start); elapsed < 80*time.Millisecond {
+		t.Errorf("requests are not rate limited: %s", elapsed)
+	}
+}
+
+func TestClientSharedLimiter(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer ts.Close()
+
+	l := NewLimiter(50)
+	start := time.Now()
+	for i := 0; i < 5; i++ {
+		// a new client for each request still waits for the shared limiter
+		c := &Client{Client: http.DefaultClient, Limiter: l}
+		if _, err := c.http(context.Background(), http.MethodGet, ts.URL, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed < 80*time.Millisecond {
+		t.Errorf("requests are not rate limited: %s", elapsed)
+	}
+}
+
+func TestClientContext(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer ts.Close()
+
+	c := &Client{
+		Client:       http.DefaultClient,
+		MaxRetries:   3,
+		RetryWaitMin: time.Minute,
+		RetryWaitMax: time.Minute,
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	_, err := c.GetFeedContext(ctx, ts.URL)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("error should be context.DeadlineExceeded but: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 10*time.Second {
+		t.Errorf("waiting for retry should be canceled: %s", elapsed)
+	}
+}
+
+func TestAPIError(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/notfound":
+			http.Error(w, "Not Found", http.StatusNotFound)
+		case "/unauthorized":
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		case "/ratelimited":
+			http.Error(w, "Too Many Requests", http.StatusTooManyRequests)
+		}
+	}))
+	defer ts.Close()
+
+	c := &Client{Client: http.DefaultClient}
+	_, err := c.GetEntry(ts.URL + "/notfound")
+	var ae *APIError
+	if !errors.As(err, &ae) {
+		t.Fatalf("error should be *APIError but: %#v", err)
+	}
+	expect := &APIError{
+		StatusCode: http.StatusNotFound,
+		Status:     "404 Not Found",
+		Method:     http.MethodGet,
+		URL:        ts.URL + "/notfound",
+		Body:       "Not Found",
+	}
+	if *ae != *expect {
+		t.Errorf("got %+v, want %+v", ae, expect)
+	}
+
+	testCases := []struct {
+		path                                string
+		notFound, unauthorized, rateLimited bool
+	}{
+		{path: "/notfound", notFound: true},
+		{path: "/unauthorized", unauthorized: true},
+		{path: "/ratelimited", rateLimited: true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.path, func(t *testing.T) {
+			err := c.DeleteEntry(ts.URL + tc.path)
+			if g := IsNotFound(err); g != tc.notFound {
+				t.Errorf("IsNotFound: got %t, want %t", g, tc.notFound)
+			}
+			if g := IsUnauthorized(err); g != tc.unauthorized {
+				t.Errorf("IsUnauthorized: got %t, want %t", g, tc.unauthorized)
+			}
+			if g := IsRateLimited(err); g != tc.rateLimited {
+				t.Errorf("IsRateLimited: got %t, want %t", g, tc.rateLimited)
+			}
+		})
+	}
+}
@@ -0,0 +1,41 @@
+package atom
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// APIError is the error returned when the API responds with a non-2xx status
+type APIError struct {
+	StatusCode int
+	Status     string
+	Method     string
+	URL        string
+	// Body is the response body, which is usually a short message in text
+	Body string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("%s %s: got [%s]: %q", e.Method, e.URL, e.Status, e.Body)
+}
+
+func hasStatus(err error, code int) bool {
+	var ae *APIError
+	return errors.As(err, &ae) && ae.StatusCode == code
+}
+
+// IsNotFound reports whether err is an APIError of 404 Not Found
+func IsNotFound(err error) bool {
+	return hasStatus(err, http.StatusNotFound)
+}
+
+// IsUnauthorized reports whether err is an APIError of 401 Unauthorized
+func IsUnauthorized(err error) bool {
+	return hasStatus(err, http.StatusUnauthorized)
+}
+
+// IsRateLimited reports whether err is an APIError of 429 Too Many Requests
+func IsRateLimited(err error) bool {
+	return hasStatus(err, http.StatusTooManyRequests)
+}
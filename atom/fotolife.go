@@ -0,0 +1,77 @@
+package atom
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/xml"
+	"io"
+)
+
+// FotolifeEndpoint is the URL to post images to Hatena Fotolife
+const FotolifeEndpoint = "https://f.hatena.ne.jp/atom/post"
+
+// Image represents an image entry of the Hatena Fotolife AtomPub API, which is
+// based on Atom 0.3 unlike the Hatena Blog one
+type Image struct {
+	XMLName xml.Name     `xml:"http://purl.org/atom/ns# entry"`
+	ID      string       `xml:"id,omitempty"`
+	Title   string       `xml:"title"`
+	Links   Links        `xml:"link,omitempty"`
+	Content ImageContent `xml:"content"`
+	// Folder is the folder of Fotolife in which the image is stored
+	Folder string `xml:"http://purl.org/dc/elements/1.1/ subject,omitempty"`
+	// Syntax is the Hatena syntax to embed the image such as "f:id:motemen:20140101123456p:image"
+	Syntax string `xml:"http://www.hatena.ne.jp/info/xmlns# syntax,omitempty"`
+	// ImageURL is the URL of the uploaded image
+	ImageURL string `xml:"http://www.hatena.ne.jp/info/xmlns# imageurl,omitempty"`
+}
+
+// ImageContent represents the content of an image entry
+type ImageContent struct {
+	Mode    string `xml:"mode,attr,omitempty"`
+	Type    string `xml:"type,attr,omitempty"`
+	Content string `xml:",chardata"`
+}
+
+// NewImage returns an Image to post data of the contentType such as "image/png"
+func NewImage(title, contentType string, data []byte) *Image {
+	return &Image{
+		Title: title,
+		Content: ImageContent{
+			Mode:    "base64",
+			Type:    contentType,
+			Content: base64.StdEncoding.EncodeToString(data),
+		},
+	}
+}
+
+// ParseImage parses an image entry xml from r and returns Image
+func ParseImage(r io.Reader) (*Image, error) {
+	img := &Image{}
+	if err := xml.NewDecoder(r).Decode(img); err != nil {
+		return nil, err
+	}
+	return img, nil
+}
+
+// PostImage posts the image to Hatena Fotolife
+func (c *Client) PostImage(url string, img *Image) (*Image, error) {
+	return c.PostImageContext(context.Background(), url, img)
+}
+
+// PostImageContext is like PostImage but with a context
+func (c *Client) PostImageContext(ctx context.Context, url string, img *Image) (*Image, error) {
+	body := new(bytes.Buffer)
+	body.WriteString(xml.Header)
+	if err := xml.NewEncoder(body).Encode(img); err != nil {
+		return nil, err
+	}
+
+	resp, err := c.http(ctx, "POST", url, body)
+	if err != nil {
+		return nil, err
+	}
+
+	return ParseImage(resp.Body)
+}
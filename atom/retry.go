@@ -0,0 +1,175 @@
+package atom
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	defaultRetryWaitMin = 1 * time.Second
+	defaultRetryWaitMax = 30 * time.Second
+)
+
+// Limiter paces requests at a constant interval. It can be shared by clients
+// sending requests to the same server.
+type Limiter struct {
+	mu       sync.Mutex
+	interval time.Duration
+	next     time.Time
+}
+
+// NewLimiter returns a Limiter which allows perSecond requests per second.
+func NewLimiter(perSecond float64) *Limiter {
+	return &Limiter{interval: time.Duration(float64(time.Second) / perSecond)}
+}
+
+func (l *Limiter) wait(ctx context.Context) error {
+	l.mu.Lock()
+	now := time.Now()
+	if l.next.Before(now) {
+		l.next = now
+	}
+	d := l.next.Sub(now)
+	l.next = l.next.Add(l.interval)
+	l.mu.Unlock()
+
+	return sleep(ctx, d)
+}
+
+// sleep pauses for d or until ctx is done.
+func sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
+
+func (c *Client) waitRateLimit(ctx context.Context) error {
+	c.limiterOnce.Do(func() {
+		if c.Limiter == nil && c.RateLimit > 0 {
+			c.Limiter = NewLimiter(c.RateLimit)
+		}
+	})
+	if c.Limiter != nil {
+		return c.Limiter.wait(ctx)
+	}
+	return ctx.Err()
+}
+
+func isIdempotent(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+// permanentError is implemented by the errors of the transport which would fail
+// again on retry, such as the failure to resolve the credentials.
+type permanentError interface {
+	Permanent() bool
+}
+
+func shouldRetry(resp *http.Response, err error) bool {
+	if err != nil {
+		var pe permanentError
+		return !errors.As(err, &pe) || !pe.Permanent()
+	}
+	return resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
+}
+
+// retryAfter parses Retry-After header of 429 and 503 responses, which is either
+// delay seconds or an HTTP date. It returns 0 if not available.
+func retryAfter(resp *http.Response) time.Duration {
+	if resp == nil ||
+		(resp.StatusCode != http.StatusTooManyRequests && resp.StatusCode != http.StatusServiceUnavailable) {
+		return 0
+	}
+	v := resp.Header.Get("Retry-After")
+	if v == "" {
+		return 0
+	}
+	if sec, err := strconv.Atoi(v); err == nil && sec >= 0 {
+		return time.Duration(sec) * time.Second
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		if d := time.Until(t); d > 0 {
+			return d
+		}
+	}
+	return 0
+}
+
+// backoff returns the wait before the retry of the given attempt, which grows
+// exponentially with jitter between RetryWaitMin and RetryWaitMax.
+func (c *Client) backoff(attempt int) time.Duration {
+	waitMin, waitMax := c.RetryWaitMin, c.RetryWaitMax
+	if waitMin <= 0 {
+		waitMin = defaultRetryWaitMin
+	}
+	if waitMax <= 0 {
+		waitMax = defaultRetryWaitMax
+	}
+	d := waitMin << attempt
+	if d <= 0 || d > waitMax {
+		d = waitMax
+	}
+	// equal jitter: half of the wait is fixed and the rest is random
+	return d/2 + rand.N(d/2+1)
+}
+
+func (c *Client) doWithRetry(ctx context.Context, method, url string, body io.Reader) (*http.Response, error) {
+	var bodyBytes []byte
+	if body != nil {
+		var err error
+		bodyBytes, err = io.ReadAll(body)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	for attempt := 0; ; attempt++ {
+		var reqBody io.Reader
+		if body != nil {
+			reqBody = bytes.NewReader(bodyBytes)
+		}
+		req, err := http.NewRequestWithContext(ctx, method, url, reqBody)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := c.waitRateLimit(ctx); err != nil {
+			return nil, err
+		}
+		resp, err := c.Client.Do(req)
+		if attempt >= c.MaxRetries || !isIdempotent(method) || !shouldRetry(resp, err) || ctx.Err() != nil {
+			return resp, err
+		}
+
+		wait := retryAfter(resp)
+		if wait == 0 {
+			wait = c.backoff(attempt)
+		}
+		if resp != nil {
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+		if err := sleep(ctx, wait); err != nil {
+			return nil, err
+		}
+	}
+}
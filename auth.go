@@ -0,0 +1,365 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/motemen/go-wsse"
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	// authWSSE authenticates with the username and the API key by WSSE (default)
+	authWSSE = "wsse"
+	// authBasic authenticates with the username and the API key by Basic authentication
+	authBasic = "basic"
+	// authOAuth1 authenticates with the access token of OAuth 1.0a
+	authOAuth1 = "oauth1"
+)
+
+// The endpoints of OAuth of Hatena
+const (
+	oauthInitiateURL  = "https://www.hatena.com/oauth/initiate"
+	oauthAuthorizeURL = "https://www.hatena.ne.jp/oauth/authorize"
+	oauthTokenURL     = "https://www.hatena.com/oauth/token"
+)
+
+// oauthScope is the scope to read and write both published entries and drafts
+const oauthScope = "read_public,write_public,read_private,write_private"
+
+func isValidAuth(auth string) bool {
+	switch auth {
+	case "", authWSSE, authBasic, authOAuth1:
+		return true
+	}
+	return false
+}
+
+func (bc *blogConfig) authMethod() string {
+	if bc.Auth == "" {
+		return authWSSE
+	}
+	return bc.Auth
+}
+
+// oauthURL returns the URL of the OAuth endpoint u, which is replaced with the
+// one under oauth_endpoint if it is set for testing.
+func (bc *blogConfig) oauthURL(u string) string {
+	if bc.OAuthEndpoint == "" {
+		return u
+	}
+	return strings.TrimSuffix(bc.OAuthEndpoint, "/") + u[strings.LastIndex(u, "/"):]
+}
+
+// transport returns the http.RoundTripper to authenticate the API requests by
+// the auth method of the blog.
+func (bc *blogConfig) transport() http.RoundTripper {
+	if bc.authMethod() == authOAuth1 {
+		return &oauth1Transport{credentials: oauthCredentials{
+			ConsumerKey:    bc.ConsumerKey,
+			ConsumerSecret: bc.ConsumerSecret,
+			Token:          bc.AccessToken,
+			TokenSecret:    bc.AccessTokenSecret,
+		}}
+	}
+	return &passwordTransport{bc: bc}
+}
+
+// passwordTransport authenticates requests with the username and the API key by
+// WSSE or Basic authentication. The API key is resolved on the first request, so
+// that password_command only runs for the commands which call the API.
+type passwordTransport struct {
+	bc   *blogConfig
+	once sync.Once
+	rt   http.RoundTripper
+	err  error
+}
+
+func (t *passwordTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.once.Do(func() {
+		password, err := t.bc.password()
+		if err != nil {
+			t.err = &credentialError{err}
+			return
+		}
+		if t.bc.authMethod() == authBasic {
+			t.rt = &basicTransport{username: t.bc.Username, password: password}
+		} else {
+			t.rt = &wsse.Transport{Username: t.bc.Username, Password: password}
+		}
+	})
+	if t.err != nil {
+		return nil, t.err
+	}
+	return t.rt.RoundTrip(req)
+}
+
+// credentialError is the failure to resolve the credentials, which is not
+// retried by the client.
+type credentialError struct {
+	err error
+}
+
+func (e *credentialError) Error() string   { return e.err.Error() }
+func (e *credentialError) Unwrap() error   { return e.err }
+func (e *credentialError) Permanent() bool { return true }
+
+// basicTransport adds the Authorization header of Basic authentication to requests.
+type basicTransport struct {
+	username, password string
+}
+
+func (t *basicTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	r := req.Clone(req.Context())
+	r.SetBasicAuth(t.username, t.password)
+	return http.DefaultTransport.RoundTrip(r)
+}
+
+// oauth1Transport signs requests with the OAuth 1.0a access token.
+type oauth1Transport struct {
+	credentials oauthCredentials
+}
+
+func (t *oauth1Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	auth, err := t.credentials.authorization(req.Method, req.URL.String(), nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	r := req.Clone(req.Context())
+	r.Header.Set("Authorization", auth)
+	return http.DefaultTransport.RoundTrip(r)
+}
+
+// oauthCredentials is the pair of the consumer and the token of OAuth 1.0a. The
+// token is either the temporary one in the authorization flow or the access token.
+type oauthCredentials struct {
+	ConsumerKey, ConsumerSecret string
+	Token, TokenSecret          string
+}
+
+// oauthEscape percent-encodes s as required for the signature by RFC 5849.
+func oauthEscape(s string) string {
+	var sb strings.Builder
+	for _, c := range []byte(s) {
+		if 'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' || '0' <= c && c <= '9' ||
+			c == '-' || c == '.' || c == '_' || c == '~' {
+			sb.WriteByte(c)
+		} else {
+			fmt.Fprintf(&sb, "%%%02X", c)
+		}
+	}
+	return sb.String()
+}
+
+// authorization returns the value of the Authorization header of the request
+// signed by HMAC-SHA1. form is the parameters in the form encoded body, and
+// oauthParams are the additional protocol parameters such as oauth_verifier.
+func (c *oauthCredentials) authorization(method, rawURL string, form url.Values, oauthParams map[string]string) (string, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, 16)
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	params := map[string]string{
+		"oauth_consumer_key":     c.ConsumerKey,
+		"oauth_nonce":            hex.EncodeToString(nonce),
+		"oauth_signature_method": "HMAC-SHA1",
+		"oauth_timestamp":        strconv.FormatInt(time.Now().Unix(), 10),
+		"oauth_version":          "1.0",
+	}
+	if c.Token != "" {
+		params["oauth_token"] = c.Token
+	}
+	for k, v := range oauthParams {
+		params[k] = v
+	}
+
+	var pairs []string
+	for k, v := range params {
+		pairs = append(pairs, oauthEscape(k)+"="+oauthEscape(v))
+	}
+	for _, vs := range []url.Values{u.Query(), form} {
+		for k, values := range vs {
+			for _, v := range values {
+				pairs = append(pairs, oauthEscape(k)+"="+oauthEscape(v))
+			}
+		}
+	}
+	sort.Strings(pairs)
+	baseURL := strings.ToLower(u.Scheme) + "://" + strings.ToLower(u.Host) + u.EscapedPath()
+	base := strings.ToUpper(method) + "&" + oauthEscape(baseURL) + "&" + oauthEscape(strings.Join(pairs, "&"))
+
+	mac := hmac.New(sha1.New, []byte(oauthEscape(c.ConsumerSecret)+"&"+oauthEscape(c.TokenSecret)))
+	mac.Write([]byte(base))
+	params["oauth_signature"] = base64.StdEncoding.EncodeToString(mac.Sum(nil))
+
+	keys := make([]string, 0, len(params))
+	for k := range params {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	fields := make([]string, len(keys))
+	for i, k := range keys {
+		fields[i] = fmt.Sprintf(`%s="%s"`, oauthEscape(k), oauthEscape(params[k]))
+	}
+	return "OAuth " + strings.Join(fields, ", "), nil
+}
+
+// request posts form to the OAuth endpoint and returns the parameters in the response.
+func (c *oauthCredentials) request(ctx context.Context, endpoint string, form url.Values, oauthParams map[string]string) (url.Values, error) {
+	auth, err := c.authorization("POST", endpoint, form, oauthParams)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", auth)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	resp, err := (&http.Client{Timeout: requestTimeout}).Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("got %s from %s: %s", resp.Status, endpoint, strings.TrimSpace(string(body)))
+	}
+	return url.ParseQuery(string(body))
+}
+
+// oauthLogin runs the authorization flow of OAuth 1.0a for the out-of-band
+// client. It shows the URL to allow the access to w, reads the verification
+// code from r and returns the access token.
+func oauthLogin(ctx context.Context, bc *blogConfig, r io.Reader, w io.Writer) (*storedToken, error) {
+	if bc.ConsumerKey == "" || bc.ConsumerSecret == "" {
+		return nil, fmt.Errorf("consumer_key and consumer_secret of %s are required", bc.BlogID)
+	}
+	c := &oauthCredentials{ConsumerKey: bc.ConsumerKey, ConsumerSecret: bc.ConsumerSecret}
+	res, err := c.request(ctx, bc.oauthURL(oauthInitiateURL),
+		url.Values{"scope": {oauthScope}}, map[string]string{"oauth_callback": "oob"})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get the request token: %w", err)
+	}
+	c.Token, c.TokenSecret = res.Get("oauth_token"), res.Get("oauth_token_secret")
+	if c.Token == "" {
+		return nil, fmt.Errorf("no request token in the response")
+	}
+
+	fmt.Fprintf(w, "Open the following URL in your browser and allow the access:\n\n  %s?oauth_token=%s\n\n",
+		bc.oauthURL(oauthAuthorizeURL), url.QueryEscape(c.Token))
+	fmt.Fprint(w, "Enter the verification code: ")
+	line, err := bufio.NewReader(r).ReadString('\n')
+	verifier := strings.TrimSpace(line)
+	if verifier == "" {
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+		return nil, fmt.Errorf("no verification code is entered")
+	}
+
+	res, err = c.request(ctx, bc.oauthURL(oauthTokenURL), nil, map[string]string{"oauth_verifier": verifier})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get the access token: %w", err)
+	}
+	t := &storedToken{AccessToken: res.Get("oauth_token"), AccessTokenSecret: res.Get("oauth_token_secret")}
+	if t.AccessToken == "" {
+		return nil, fmt.Errorf("no access token in the response")
+	}
+	return t, nil
+}
+
+// storedToken is the access token stored by auth login.
+type storedToken struct {
+	AccessToken       string `yaml:"access_token"`
+	AccessTokenSecret string `yaml:"access_token_secret"`
+}
+
+// tokenStorePath returns the path to the file in which the access tokens are
+// stored keyed by blog ID.
+func tokenStorePath() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".config", "blogsync", "tokens.yaml"), nil
+}
+
+func loadStoredTokens() (map[string]*storedToken, error) {
+	tokens := map[string]*storedToken{}
+	p, err := tokenStorePath()
+	if err != nil {
+		return tokens, nil
+	}
+	bb, err := os.ReadFile(p)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return tokens, nil
+		}
+		return nil, err
+	}
+	if err := yaml.Unmarshal(bb, &tokens); err != nil {
+		return nil, fmt.Errorf("%s: %w", p, err)
+	}
+	return tokens, nil
+}
+
+// saveStoredToken stores the access token of the blog, and returns the path to
+// the file. The file is only readable by the user.
+func saveStoredToken(blogID string, t *storedToken) (string, error) {
+	p, err := tokenStorePath()
+	if err != nil {
+		return "", err
+	}
+	tokens, err := loadStoredTokens()
+	if err != nil {
+		return "", err
+	}
+	tokens[blogID] = t
+	bb, err := yaml.Marshal(tokens)
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+		return "", err
+	}
+	return p, os.WriteFile(p, bb, 0600)
+}
+
+// applyStoredTokens sets the stored access tokens to the blogs which do not have
+// ones in the configuration.
+func (c *config) applyStoredTokens() error {
+	tokens, err := loadStoredTokens()
+	if err != nil {
+		return err
+	}
+	for blogID, bc := range c.Blogs {
+		if t := tokens[blogID]; t != nil && bc.AccessToken == "" {
+			bc.AccessToken, bc.AccessTokenSecret = t.AccessToken, t.AccessTokenSecret
+		}
+	}
+	return nil
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/x-motemen/blogsync/hatenatest"
+)
+
+func TestAuth(t *testing.T) {
+	setup := func(t *testing.T, conf string) (*hatenatest.Server, string) {
+		t.Helper()
+		s, dir := setupFakeBlog(t)
+		s.ConsumerKey, s.ConsumerSecret = "consumer", "consumer secret"
+		addFakeEntry(t, s, "auth", "auth")
+		if err := appendFile(filepath.Join(dir, "blogsync.yaml"), conf); err != nil {
+			t.Fatal(err)
+		}
+		return s, dir
+	}
+
+	t.Run("basic", func(t *testing.T) {
+		_, dir := setup(t, "  auth: basic\n")
+		if _, err := blogsyncApp(newApp())("pull"); err != nil {
+			t.Fatal(err)
+		}
+		if !exists(filepath.Join(dir, "entry", "auth.md")) {
+			t.Errorf("entry should be pulled")
+		}
+	})
+
+	t.Run("unknown", func(t *testing.T) {
+		setup(t, "  auth: digest\n")
+		_, err := blogsyncApp(newApp())("pull")
+		if err == nil || !strings.Contains(err.Error(), "unknown auth") {
+			t.Errorf("unknown auth should be an error: %v", err)
+		}
+	})
+
+	t.Run("oauth1", func(t *testing.T) {
+		s, dir := setup(t, strings.Join([]string{
+			"  auth: oauth1",
+			"  consumer_key: consumer",
+			"  consumer_secret: consumer secret",
+			"",
+		}, "\n"))
+		if err := appendFile(filepath.Join(dir, "blogsync.yaml"), "  oauth_endpoint: "+s.OAuthEndpoint()+"\n"); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := blogsyncApp(newApp())("pull"); err == nil {
+			t.Errorf("pull without the access token should fail")
+		}
+
+		app := newApp()
+		app.ErrWriter = io.Discard
+		blogsync := blogsyncApp(app)
+		app.Reader = strings.NewReader("wrong\n")
+		if _, err := blogsync("auth", "login", fakeBlogID); err == nil {
+			t.Errorf("login with the wrong verifier should fail")
+		}
+		app.Reader = strings.NewReader(hatenatest.OAuthVerifier + "\n")
+		if _, err := blogsync("auth", "login", fakeBlogID); err != nil {
+			t.Fatal(err)
+		}
+		tokenFile := filepath.Join(dir, ".config", "blogsync", "tokens.yaml")
+		fi, err := os.Stat(tokenFile)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if fi.Mode().Perm() != 0600 {
+			t.Errorf("token file should be only readable by the user: %s", fi.Mode())
+		}
+
+		if _, err := blogsync("pull"); err != nil {
+			t.Fatal(err)
+		}
+		if !exists(filepath.Join(dir, "entry", "auth.md")) {
+			t.Errorf("entry should be pulled")
+		}
+	})
+
+	t.Run("oauth1 access token in config", func(t *testing.T) {
+		s, _ := setup(t, strings.Join([]string{
+			"  auth: oauth1",
+			"  consumer_key: consumer",
+			"  consumer_secret: consumer secret",
+			"  access_token: token",
+			"  access_token_secret: token/secret",
+			"",
+		}, "\n"))
+		s.AddAccessToken("token", "token/secret")
+		if _, err := blogsyncApp(newApp())("pull"); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
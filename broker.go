@@ -2,14 +2,18 @@ package main
 
 import (
 	"bufio"
+	"bytes"
+	"context"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
+	"time"
 
-	"github.com/motemen/go-wsse"
 	"github.com/x-motemen/blogsync/atom"
 )
 
@@ -17,6 +21,43 @@ type broker struct {
 	*atom.Client
 	*blogConfig
 	writer io.Writer
+	// staticPageUnavailable is set when static pages could not be fetched
+	staticPageUnavailable bool
+	// out buffers the log and the output when the broker works in a concurrent job
+	out *outputBuffer
+	// withHTML makes pull store the rendered HTML next to each entry
+	withHTML bool
+	// withAssets makes pull download the images referenced in each entry
+	withAssets bool
+}
+
+const defaultRequestTimeout = time.Minute
+
+// requestTimeout is the timeout of each API request set by --timeout flag.
+var requestTimeout = defaultRequestTimeout
+
+// rateLimiters holds the limiters of requests keyed by endpoint and blog ID, so
+// that the brokers created for each file in bulk operations share rate_limit.
+var rateLimiters = struct {
+	sync.Mutex
+	m map[string]*atom.Limiter
+}{m: map[string]*atom.Limiter{}}
+
+// rateLimiter returns the limiter of the blog shared in the process, or nil if
+// rate_limit is not set.
+func (bc *blogConfig) rateLimiter() *atom.Limiter {
+	if bc.RateLimit <= 0 {
+		return nil
+	}
+	key := bc.endpoint() + bc.BlogID
+	rateLimiters.Lock()
+	defer rateLimiters.Unlock()
+	if l, ok := rateLimiters.m[key]; ok {
+		return l
+	}
+	l := atom.NewLimiter(bc.RateLimit)
+	rateLimiters.m[key] = l
+	return l
 }
 
 func newBroker(bc *blogConfig, w io.Writer) *broker {
@@ -26,17 +67,37 @@ func newBroker(bc *blogConfig, w io.Writer) *broker {
 	return &broker{
 		Client: &atom.Client{
 			Client: &http.Client{
-				Transport: &wsse.Transport{
-					Username: bc.Username,
-					Password: bc.Password,
-				},
+				Timeout:   requestTimeout,
+				Transport: bc.transport(),
 			},
+			MaxRetries: bc.maxRetries(),
+			Limiter:    bc.rateLimiter(),
 		},
 		blogConfig: bc,
 		writer:     w,
 	}
 }
 
+// withOutput returns a copy of the broker which logs and writes the output to out
+// for a concurrent job. It returns b itself if out is nil.
+func (b *broker) withOutput(out *outputBuffer) *broker {
+	if out == nil {
+		return b
+	}
+	nb := *b
+	nb.writer = out
+	nb.out = out
+	return &nb
+}
+
+func (b *broker) logf(prefix, pattern string, args ...interface{}) {
+	if b.out != nil {
+		b.out.logf(prefix, pattern, args...)
+		return
+	}
+	logf(prefix, pattern, args...)
+}
+
 // editURLFromFile extracts the EditURL from a file's frontmatter without
 // fully parsing the entry. Returns "" if not found.
 func editURLFromFile(fpath string) string {
@@ -67,27 +128,64 @@ func editURLFromFile(fpath string) string {
 	return ""
 }
 
-// buildLocalEntryMap walks the local root and builds a map of EditURL to file path
-// for all entry files.
-func (b *broker) buildLocalEntryMap() map[string]string {
-	m := map[string]string{}
+// walkLocalEntries walks the local root and calls fn with the path and the EditURL
+// of each entry file. editURL is "" for files which have not been posted yet.
+func (b *broker) walkLocalEntries(fn func(path, editURL string)) {
 	root := b.localRoot()
 	filepath.WalkDir(root, func(path string, d os.DirEntry, err error) error {
-		if err != nil || d.IsDir() {
+		if err != nil {
 			return nil
 		}
-		if !strings.HasSuffix(path, entryExt) {
+		if d.IsDir() {
+			if d.Name() == stateDir {
+				return filepath.SkipDir
+			}
 			return nil
 		}
-		if editURL := editURLFromFile(path); editURL != "" {
-			m[editURL] = path
+		if entryFileExt(path) == "" || isSidecar(path) || isFormattedHTML(path) {
+			return nil
 		}
+		fn(path, editURLFromFile(path))
 		return nil
 	})
+}
+
+// walkEntryFiles is like walkLocalEntries but only visits the entries, that is,
+// the files with EditURL and the new ones in the entry directory which push posts.
+// Other files in the local root such as README.md are skipped.
+func (b *broker) walkEntryFiles(fn func(path, editURL string)) {
+	b.walkLocalEntries(func(path, editURL string) {
+		if editURL == "" {
+			if _, entryPath := b.blogConfig.extractEntryPath(path); entryPath == "" {
+				return
+			}
+		}
+		fn(path, editURL)
+	})
+}
+
+// buildLocalEntryMap builds a map of EditURL to file path for all entry files.
+// The index in the sync state is used if available, otherwise it walks the local
+// root and rebuilds the index.
+func (b *broker) buildLocalEntryMap() map[string]string {
+	if m, ok := b.indexedEntryMap(); ok {
+		return m
+	}
+	m := map[string]string{}
+	for editURL, es := range b.Reindex(nil).Entries {
+		m[editURL] = b.absPath(es.Path)
+	}
 	return m
 }
 
-func (b *broker) FetchRemoteEntries(published, drafts bool) ([]*entry, error) {
+func (b *broker) FetchRemoteEntries(ctx context.Context, published, drafts bool) ([]*entry, error) {
+	return b.FetchRemoteEntriesSince(ctx, published, drafts, time.Time{})
+}
+
+// FetchRemoteEntriesSince is like FetchRemoteEntries but stops paging once it
+// reaches a page whose entries have been edited before since, relying on the
+// feed being ordered by app:edited. A zero since fetches all entries.
+func (b *broker) FetchRemoteEntriesSince(ctx context.Context, published, drafts bool, since time.Time) ([]*entry, error) {
 	entries := []*entry{}
 	staticPageURL := staticPageEndpointURL(b.blogConfig)
 	urls := []string{
@@ -102,14 +200,15 @@ func (b *broker) FetchRemoteEntries(published, drafts bool) ([]*entry, error) {
 			url, urls = urls[0], urls[1:]
 		}
 
-		feed, err := b.Client.GetFeed(url)
+		feed, err := b.Client.GetFeedContext(ctx, url)
 		if err != nil {
-			if url == staticPageURL {
-				// Ignore errors in the case of static pages, because static page is the feature
-				// only for pro users.
+			if url == staticPageURL && isPageUnavailable(err) {
+				// Ignore client errors in the case of static pages, because static page is
+				// the feature only for pro users.
+				b.staticPageUnavailable = true
 				break
 			}
-			return nil, err
+			return nil, b.apiError(err)
 		}
 		if b.rootURL == "" {
 			if l := feed.Links.Find("alternate"); l != nil {
@@ -128,6 +227,10 @@ func (b *broker) FetchRemoteEntries(published, drafts bool) ([]*entry, error) {
 		}
 
 		nextLink := feed.Links.Find("next")
+		if n := len(feed.Entries); !since.IsZero() && n > 0 &&
+			feed.Entries[n-1].Edited != nil && feed.Entries[n-1].Edited.Before(since) {
+			nextLink = nil
+		}
 		if nextLink != nil {
 			url = nextLink.Href
 		} else {
@@ -138,8 +241,6 @@ func (b *broker) FetchRemoteEntries(published, drafts bool) ([]*entry, error) {
 	return entries, nil
 }
 
-const entryExt = ".md" // TODO regard re.ContentType
-
 func (b *broker) LocalPath(e *entry) string {
 	if e.localPath != "" {
 		return e.localPath
@@ -150,26 +251,34 @@ func (b *broker) LocalPath(e *entry) string {
 	localPath := e.URL.Path
 
 	if e.IsDraft && e.isBlogEntry() {
-		subdir, entryPath := b.blogConfig.extractEntryPath(e.URL.Path)
+		subdir, entryPath := b.blogConfig.extractURLEntryPath(e.URL.Path)
 		if entryPath == "" {
 			return ""
 		}
 		if isLikelyGivenPath(entryPath) {
-			// EditURL is like bellow
-			//   https://blog.hatena.ne.jp/Songmu/songmu.hatenadiary.org/atom/entry/6801883189050452361
-			paths := strings.Split(e.EditURL, "/")
-			if len(paths) == 8 {
-				localPath = subdir + b.blogConfig.entryDirectory() + draftDir + paths[7] // path[7] is entryID
+			if entryID := e.entryID(); entryID != "" {
+				localPath = subdir + b.blogConfig.entryDirectory() + draftDir + entryID
 			}
 		}
 	}
-	return filepath.Join(b.localRoot(), localPath+entryExt)
+	return filepath.Join(b.localRoot(), localPath+extForContentType(e.ContentType))
 }
 
 func (b *broker) StoreFresh(e *entry, path string) (bool, error) {
+	if localChanged, remoteChanged, ok := b.changedSinceSync(path, e); ok && fileExists(path) {
+		switch {
+		case !remoteChanged:
+			return false, nil
+		case localChanged:
+			return false, b.writeConflict(path, e)
+		}
+		b.logf("fresh", "remote=%s has been changed since last sync", e.LastModified)
+		return true, b.Store(e, path, "")
+	}
+
 	localLastModified, _ := modTime(path)
 	if e.LastModified.After(localLastModified) {
-		logf("fresh", "remote=%s > local=%s", e.LastModified, localLastModified)
+		b.logf("fresh", "remote=%s > local=%s", e.LastModified, localLastModified)
 		if err := b.Store(e, path, ""); err != nil {
 			return false, err
 		}
@@ -180,25 +289,59 @@ func (b *broker) StoreFresh(e *entry, path string) (bool, error) {
 	return false, nil
 }
 
-func (b *broker) Store(e *entry, path, origPath string) error {
-	logf("store", "%s", path)
-
+// fileContent returns the content which Store writes to path for e.
+func (b *broker) fileContent(e *entry, path string) string {
+	eh := *e.entryHeader
+	if eh.Extra == nil {
+		// Keep the front matter keys unknown to blogsync in the local file
+		eh.Extra = localExtraHeaders(path)
+	}
 	if e.IsDraft && e.isBlogEntry() && e.URL != nil {
 		// Clear temporary URL for entries stored in _draft/
 		_, destEntryPath := b.blogConfig.extractEntryPath(path)
 		if strings.HasPrefix(destEntryPath, draftDir) {
-			e.URL = nil
+			eh.URL = nil
 		}
 	}
+	ce := *e
+	ce.entryHeader = &eh
+	return ce.fullContent()
+}
+
+// localExtraHeaders returns the extra front matter of the local file at path if any.
+func localExtraHeaders(path string) []*frontMatterItem {
+	bb, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	le, err := entryFromReader(bytes.NewReader(bb))
+	if err != nil {
+		return nil
+	}
+	return le.Extra
+}
+
+func (b *broker) Store(e *entry, path, origPath string) error {
+	if dryRun {
+		b.plan("write %s", path)
+		if origPath != "" && path != origPath {
+			b.plan("delete %s", origPath)
+		}
+		return nil
+	}
+	b.logf("store", "%s", path)
 
 	dir := filepath.Dir(path)
 	if err := os.MkdirAll(dir, 0755); err != nil {
 		return err
 	}
 
-	if err := os.WriteFile(path, []byte(e.fullContent()), 0666); err != nil {
+	content := []byte(b.fileContent(e, path))
+	if err := os.WriteFile(path, content, 0666); err != nil {
 		return err
 	}
+	fileModified(path)
+	b.recordSync(e, path, content)
 	fmt.Fprintln(b.writer, path)
 
 	if err := os.Chtimes(path, *e.LastModified, *e.LastModified); err != nil {
@@ -212,37 +355,73 @@ func (b *broker) Store(e *entry, path, origPath string) error {
 	return nil
 }
 
-func (b *broker) UploadFresh(e *entry) (bool, error) {
-	re, err := asEntry(b.Client.GetEntry(e.EditURL))
+func (b *broker) UploadFresh(ctx context.Context, e *entry) (bool, error) {
+	re, err := b.fetchEntry(ctx, e.localPath, e.EditURL)
 	if err != nil {
 		return false, err
 	}
+	// Files pulled by older versions are .md regardless of the syntax of entries,
+	// so the syntax is not changed by the extension .md.
+	if e.ContentType == contentTypeMarkdown && re.ContentType != "" {
+		e.ContentType = re.ContentType
+	}
 
 	// Force upload when CustomPath differs from current URL path
+	pathChanged := false
 	if e.CustomPath != "" && re.URL != nil {
-		_, currentEntryPath := b.blogConfig.extractEntryPath(re.URL.Path)
+		_, currentEntryPath := b.blogConfig.extractURLEntryPath(re.URL.Path)
 		if currentEntryPath != e.CustomPath {
-			return true, b.PutEntry(e)
+			pathChanged = true
+		} else {
+			// CustomPath matches current URL — clear it to avoid unnecessary API update
+			e.CustomPath = ""
 		}
-		// CustomPath matches current URL — clear it to avoid unnecessary API update
-		e.CustomPath = ""
 	}
 
-	if !newerWithAllowance(*e.LastModified, *re.LastModified) {
+	if localChanged, remoteChanged, ok := b.changedSinceSync(e.localPath, re); ok {
+		// The entry may be published by --publish without touching the file
+		localChanged = localChanged || pathChanged || e.IsDraft != re.IsDraft
+		switch {
+		case !localChanged:
+			if remoteChanged {
+				b.logf("warn", "remote has been changed since last sync, skipping: %s", e.localPath)
+			}
+			return false, nil
+		case remoteChanged:
+			if err := b.writeConflict(e.localPath, re); err != nil {
+				return false, err
+			}
+			return false, errConflict(e.localPath)
+		}
+		return true, b.PutEntry(ctx, e)
+	}
+
+	if !pathChanged && !newerWithAllowance(*e.LastModified, *re.LastModified) {
 		return false, nil
 	}
 
-	return true, b.PutEntry(e)
+	return true, b.PutEntry(ctx, e)
 }
 
-func (b *broker) PutEntry(e *entry) error {
-	newEntry, err := asEntry(b.Client.PutEntry(e.EditURL, e.atom()))
-	if err != nil {
+func (b *broker) PutEntry(ctx context.Context, e *entry) error {
+	if err := b.uploadImages(ctx, e); err != nil {
 		return err
 	}
+	var newEntry *entry
+	if dryRun {
+		b.plan("PUT %s", e.EditURL)
+		newEntry = b.dryRunEntry(e, e.isStaticPage())
+	} else {
+		var err error
+		newEntry, err = asEntry(b.Client.PutEntryContext(ctx, e.EditURL, e.atom()))
+		if err != nil {
+			return b.apiError(err)
+		}
+	}
+	newEntry.Extra = e.Extra
 	// Log URL change for published entries
 	if !newEntry.IsDraft && e.URL != nil && newEntry.URL != nil && e.URL.Path != newEntry.URL.Path {
-		logf("store", "URL changed: %s -> %s", e.URL.Path, newEntry.URL.Path)
+		b.logf("store", "URL changed: %s -> %s", e.URL.Path, newEntry.URL.Path)
 	}
 	// Preserve local path for drafts stored outside _draft/
 	if e.localPath != "" && newEntry.IsDraft && newEntry.isBlogEntry() {
@@ -254,17 +433,32 @@ func (b *broker) PutEntry(e *entry) error {
 	return b.Store(newEntry, b.LocalPath(newEntry), b.LocalPath(e))
 }
 
-func (b *broker) PostEntry(e *entry, isPage bool) error {
+func (b *broker) PostEntry(ctx context.Context, e *entry, isPage bool) error {
+	if err := b.uploadImages(ctx, e); err != nil {
+		return err
+	}
 	var endPoint string
 	if !isPage {
 		endPoint = entryEndPointUrl(b.blogConfig)
 	} else {
 		endPoint = staticPageEndpointURL(b.blogConfig)
 	}
-	newEntry, err := asEntry(b.Client.PostEntry(endPoint, e.atom()))
-	if err != nil {
-		return err
+	var newEntry *entry
+	if dryRun {
+		b.plan("POST %s", endPoint)
+		newEntry = b.dryRunEntry(e, isPage)
+		if newEntry.URL == nil {
+			b.plan("write the file for the URL given by the response")
+			return nil
+		}
+	} else {
+		var err error
+		newEntry, err = asEntry(b.Client.PostEntryContext(ctx, endPoint, e.atom()))
+		if err != nil {
+			return b.apiError(err)
+		}
 	}
+	newEntry.Extra = e.Extra
 	// Preserve local path for drafts stored outside _draft/
 	if e.localPath != "" && newEntry.IsDraft && newEntry.isBlogEntry() {
 		_, entryPath := b.blogConfig.extractEntryPath(e.localPath)
@@ -275,16 +469,71 @@ func (b *broker) PostEntry(e *entry, isPage bool) error {
 	return b.Store(newEntry, b.LocalPath(newEntry), "")
 }
 
-func (b *broker) RemoveEntry(e *entry) error {
-	err := b.Client.DeleteEntry(e.EditURL)
+// RemoveEntry deletes the remote entry of e and its local file, which are saved
+// into the trash directory beforehand.
+func (b *broker) RemoveEntry(ctx context.Context, e *entry) error {
+	ae, err := b.Client.GetEntryContext(ctx, e.EditURL)
+	notFound := atom.IsNotFound(err)
+	if err != nil && !notFound {
+		return b.apiError(err)
+	}
+	p := b.LocalPath(e)
+	trashed, err := b.trashEntry(e, ae, p)
 	if err != nil {
 		return err
 	}
-	p := b.LocalPath(e)
-	if _, err := os.Stat(p); err == nil {
-		return os.Remove(p)
+
+	switch {
+	case notFound:
+		b.logf("warn", "entry is already deleted on remote: %s", e.EditURL)
+	case dryRun:
+		b.plan("DELETE %s", e.EditURL)
+	default:
+		err := b.Client.DeleteEntryContext(ctx, e.EditURL)
+		if atom.IsNotFound(err) {
+			b.logf("warn", "entry is already deleted on remote: %s", e.EditURL)
+		} else if err != nil {
+			return b.apiError(err)
+		}
 	}
-	return nil
+	if !dryRun {
+		b.syncState().delete(e.EditURL)
+		if trashed != "" {
+			b.logf("trash", "%s", trashed)
+		}
+	}
+	if err := b.removeFormattedHTML(p); err != nil {
+		return err
+	}
+	return b.removeFile(p)
+}
+
+// fetchEntry gets the remote entry of the local file at path.
+func (b *broker) fetchEntry(ctx context.Context, path, editURL string) (*entry, error) {
+	re, err := asEntry(b.Client.GetEntryContext(ctx, editURL))
+	if atom.IsNotFound(err) {
+		return nil, fmt.Errorf("entry for %s is not found on remote, it may have been deleted: %w", path, err)
+	}
+	return re, b.apiError(err)
+}
+
+// apiError adds a hint to resolve the error from the API, if any.
+func (b *broker) apiError(err error) error {
+	switch {
+	case atom.IsUnauthorized(err):
+		return fmt.Errorf("authentication failed for %s, check username and password (API key) in the config: %w", b.BlogID, err)
+	case atom.IsRateLimited(err):
+		return fmt.Errorf("rate limited by the API, try again later or set rate_limit in the config: %w", err)
+	}
+	return err
+}
+
+// isPageUnavailable reports whether err from the static page endpoint means that
+// static pages are not available for the blog.
+func isPageUnavailable(err error) bool {
+	var ae *atom.APIError
+	return errors.As(err, &ae) && ae.StatusCode >= 400 && ae.StatusCode < 500 &&
+		!atom.IsUnauthorized(err) && !atom.IsRateLimited(err)
 }
 
 func atomEndpointURLRoot(bc *blogConfig) string {
@@ -292,7 +541,7 @@ func atomEndpointURLRoot(bc *blogConfig) string {
 	if owner == "" {
 		owner = bc.Username
 	}
-	return fmt.Sprintf("https://blog.hatena.ne.jp/%s/%s/atom/", owner, bc.BlogID)
+	return fmt.Sprintf("%s%s/%s/atom/", bc.endpoint(), owner, bc.BlogID)
 }
 
 func entryEndPointUrl(bc *blogConfig) string {
@@ -27,6 +27,24 @@ func TestEntryEndPointUrl(t *testing.T) {
 			},
 			expect: "https://blog.hatena.ne.jp/sample2/example1.hatenablog.com/atom/entry",
 		},
+		{
+			name: "endpoint",
+			config: blogConfig{
+				BlogID:   "example1.hatenablog.com",
+				Username: "sample1",
+				Endpoint: "http://127.0.0.1:8080",
+			},
+			expect: "http://127.0.0.1:8080/sample1/example1.hatenablog.com/atom/entry",
+		},
+		{
+			name: "endpoint with path",
+			config: blogConfig{
+				BlogID:   "example1.hatenablog.com",
+				Username: "sample1",
+				Endpoint: "http://localhost/hatena/",
+			},
+			expect: "http://localhost/hatena/sample1/example1.hatenablog.com/atom/entry",
+		},
 	}
 
 	for _, tc := range testCases {
@@ -7,6 +7,7 @@ import (
 	"path/filepath"
 	"strings"
 
+	"github.com/x-motemen/blogsync/atom"
 	"gopkg.in/yaml.v2"
 )
 
@@ -53,18 +54,34 @@ func loadConfiguration() (*config, error) {
 	if confEnv.Default.Password != "" {
 		conf.Default.Password = confEnv.Default.Password
 	}
+	if confEnv.Default.Endpoint != "" {
+		conf.Default.Endpoint = confEnv.Default.Endpoint
+	}
+	conf.applyBlogEnv()
+	if err := conf.applyStoredTokens(); err != nil {
+		return nil, err
+	}
+	if err := setTimestampSource(conf.Default); err != nil {
+		return nil, err
+	}
 
 	return conf, nil
 }
 
-func loadConfigFiles(pwd string) (*config, error) {
+// configFiles returns the paths to the local and the global configuration files
+// in the order of precedence.
+func configFiles(pwd string) []string {
 	confs := []string{filepath.Join(pwd, "blogsync.yaml")}
 	home, err := os.UserHomeDir()
 	if err == nil {
 		confs = append(confs, filepath.Join(home, ".config", "blogsync", "config.yaml"))
 	}
+	return confs
+}
+
+func loadConfigFiles(pwd string) (*config, error) {
 	var conf *config
-	for _, confFile := range confs {
+	for _, confFile := range configFiles(pwd) {
 		tmpConf, err := loadSingleConfigFile(confFile)
 		if err != nil {
 			return nil, err
@@ -108,8 +125,31 @@ type blogConfig struct {
 	OmitDomain     *bool   `yaml:"omit_domain"`
 	Owner          string  `yaml:"owner"`
 	EntryDirectory *string `yaml:"entry_directory"`
-	local          bool
-	rootURL        string
+	Endpoint       string  `yaml:"endpoint"`
+	ConflictStyle  string  `yaml:"conflict_style"`
+	MaxRetries     *int    `yaml:"max_retries"`
+	RateLimit      float64 `yaml:"rate_limit"`
+	EntryTemplate  string  `yaml:"entry_template"`
+	// PasswordCommand is the shell command to print the API key, which is used
+	// when password is not set
+	PasswordCommand string `yaml:"password_command"`
+	// Auth is the method to authenticate the API requests: wsse (default), basic or oauth1
+	Auth              string `yaml:"auth"`
+	ConsumerKey       string `yaml:"consumer_key"`
+	ConsumerSecret    string `yaml:"consumer_secret"`
+	AccessToken       string `yaml:"access_token"`
+	AccessTokenSecret string `yaml:"access_token_secret"`
+	// OAuthEndpoint is the base URL of the OAuth endpoints, which is for testing
+	OAuthEndpoint string `yaml:"oauth_endpoint"`
+	// FotolifeEndpoint is the URL to upload images to, which is for testing
+	FotolifeEndpoint string `yaml:"fotolife_endpoint"`
+	ImageSyntax      string `yaml:"image_syntax"`
+	// TimestampSource and GitUnshallow are only effective in the default section
+	// as they apply to all local files.
+	TimestampSource string `yaml:"timestamp_source"`
+	GitUnshallow    *bool  `yaml:"git_unshallow"`
+	local           bool
+	rootURL         string
 }
 
 func (bc *blogConfig) localRoot() string {
@@ -139,6 +179,43 @@ func (bc *blogConfig) entryDirectory() string {
 	return dir
 }
 
+const defaultEndpoint = "https://blog.hatena.ne.jp/"
+
+// endpoint returns the base URL of the AtomPub API with a trailing slash.
+func (bc *blogConfig) endpoint() string {
+	if bc.Endpoint == "" {
+		return defaultEndpoint
+	}
+	if !strings.HasSuffix(bc.Endpoint, "/") {
+		return bc.Endpoint + "/"
+	}
+	return bc.Endpoint
+}
+
+// conflictStyle returns how to leave conflicts, "markers" or "sidecar".
+func (bc *blogConfig) conflictStyle() string {
+	if bc.ConflictStyle == conflictStyleSidecar {
+		return conflictStyleSidecar
+	}
+	return conflictStyleMarkers
+}
+
+const defaultMaxRetries = 3
+
+func (bc *blogConfig) maxRetries() int {
+	if bc.MaxRetries == nil {
+		return defaultMaxRetries
+	}
+	return *bc.MaxRetries
+}
+
+func (bc *blogConfig) fotolifeEndpoint() string {
+	if bc.FotolifeEndpoint == "" {
+		return atom.FotolifeEndpoint
+	}
+	return bc.FotolifeEndpoint
+}
+
 func (bc *blogConfig) fetchRootURL() string {
 	if bc.rootURL != "" {
 		return bc.rootURL
@@ -181,17 +258,14 @@ func loadConfig(r io.Reader, fpath string) (*config, error) {
 		if b == nil {
 			b = &blogConfig{}
 		}
-		if b.LocalRoot != "" {
-			if b.LocalRoot == "~" || strings.HasPrefix(b.LocalRoot, "~/") {
-				home, err := os.UserHomeDir()
-				if err != nil {
-					return nil, err
-				}
-				b.LocalRoot = strings.Replace(b.LocalRoot, "~", home, 1)
-			}
-			if !filepath.IsAbs(b.LocalRoot) {
-				b.LocalRoot = filepath.Join(absDir, b.LocalRoot)
-			}
+		if b.LocalRoot, err = resolveConfigPath(b.LocalRoot, absDir); err != nil {
+			return nil, err
+		}
+		if b.EntryTemplate, err = resolveConfigPath(b.EntryTemplate, absDir); err != nil {
+			return nil, err
+		}
+		if !isValidAuth(b.Auth) {
+			return nil, fmt.Errorf("%s: unknown auth of %s: %s", fpath, key, b.Auth)
 		}
 		if b.BlogID != "default" {
 			b.BlogID = key
@@ -211,11 +285,31 @@ func loadConfig(r io.Reader, fpath string) (*config, error) {
 	}, nil
 }
 
+// resolveConfigPath expands "~" of the path p in the configuration file, and
+// resolves it relative to dir, the directory of the file.
+func resolveConfigPath(p, dir string) (string, error) {
+	if p == "" {
+		return "", nil
+	}
+	if p == "~" || strings.HasPrefix(p, "~/") {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		p = strings.Replace(p, "~", home, 1)
+	}
+	if !filepath.IsAbs(p) {
+		p = filepath.Join(dir, p)
+	}
+	return p, nil
+}
+
 func loadConfigFromEnv() (*config, error) {
 	return &config{
 		Default: &blogConfig{
 			Username: os.Getenv("BLOGSYNC_USERNAME"),
 			Password: os.Getenv("BLOGSYNC_PASSWORD"),
+			Endpoint: os.Getenv("BLOGSYNC_ENDPOINT"),
 		},
 	}, nil
 }
@@ -244,8 +338,25 @@ func mergeBlogConfig(b1, b2 *blogConfig) *blogConfig {
 	if b1.Username == "" {
 		b1.Username = b2.Username
 	}
-	if b1.Password == "" {
+	if b1.Password == "" && b1.PasswordCommand == "" {
 		b1.Password = b2.Password
+		b1.PasswordCommand = b2.PasswordCommand
+	}
+	if b1.Auth == "" {
+		b1.Auth = b2.Auth
+	}
+	if b1.ConsumerKey == "" {
+		b1.ConsumerKey = b2.ConsumerKey
+	}
+	if b1.ConsumerSecret == "" {
+		b1.ConsumerSecret = b2.ConsumerSecret
+	}
+	if b1.AccessToken == "" {
+		b1.AccessToken = b2.AccessToken
+		b1.AccessTokenSecret = b2.AccessTokenSecret
+	}
+	if b1.OAuthEndpoint == "" {
+		b1.OAuthEndpoint = b2.OAuthEndpoint
 	}
 	if b1.OmitDomain == nil {
 		b1.OmitDomain = b2.OmitDomain
@@ -253,6 +364,33 @@ func mergeBlogConfig(b1, b2 *blogConfig) *blogConfig {
 	if b1.EntryDirectory == nil {
 		b1.EntryDirectory = b2.EntryDirectory
 	}
+	if b1.Endpoint == "" {
+		b1.Endpoint = b2.Endpoint
+	}
+	if b1.ConflictStyle == "" {
+		b1.ConflictStyle = b2.ConflictStyle
+	}
+	if b1.MaxRetries == nil {
+		b1.MaxRetries = b2.MaxRetries
+	}
+	if b1.RateLimit == 0 {
+		b1.RateLimit = b2.RateLimit
+	}
+	if b1.FotolifeEndpoint == "" {
+		b1.FotolifeEndpoint = b2.FotolifeEndpoint
+	}
+	if b1.ImageSyntax == "" {
+		b1.ImageSyntax = b2.ImageSyntax
+	}
+	if b1.EntryTemplate == "" {
+		b1.EntryTemplate = b2.EntryTemplate
+	}
+	if b1.TimestampSource == "" {
+		b1.TimestampSource = b2.TimestampSource
+	}
+	if b1.GitUnshallow == nil {
+		b1.GitUnshallow = b2.GitUnshallow
+	}
 	if !b1.local {
 		b1.local = b2.local
 	}
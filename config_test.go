@@ -67,6 +67,7 @@ func TestLoadConfigration(t *testing.T) {
 		name        string
 		envUsername string
 		envPassword string
+		env         map[string]string
 		localConf   *string
 		globalConf  *string
 
@@ -279,6 +280,49 @@ func TestLoadConfigration(t *testing.T) {
 				local:     true,
 			},
 		},
+		{
+			name:        "per-blog system environment has priority over blog conf",
+			envUsername: "mmm",
+			envPassword: "pww",
+			env: map[string]string{
+				"BLOGSYNC_USERNAME_BLOG1_EXAMPLE_COM": "blog1user",
+				"BLOGSYNC_PASSWORD_BLOG1_EXAMPLE_COM": "blog1pw",
+			},
+			localConf: pstr(`---
+              blog1.example.com:
+                username: username
+                password: password
+                local_root: /data
+              blog2.example.com:
+                local_root: /blog2`),
+			globalConf: nil,
+			blogKey:    "blog1.example.com",
+			expect: blogConfig{
+				BlogID:    "blog1.example.com",
+				LocalRoot: "/data",
+				Username:  "blog1user",
+				Password:  "blog1pw",
+				local:     true,
+			},
+		},
+		{
+			name:      "password_command of blog has priority over default password",
+			localConf: nil,
+			globalConf: pstr(`---
+              default:
+                username: hoge
+                password: fuga
+                local_root: /data
+              blog1.example.com:
+                password_command: pass show blog1`),
+			blogKey: "blog1.example.com",
+			expect: blogConfig{
+				BlogID:          "blog1.example.com",
+				LocalRoot:       "/data",
+				Username:        "hoge",
+				PasswordCommand: "pass show blog1",
+			},
+		},
 		{
 			name:      "inherit default config, and no system environment",
 			localConf: nil,
@@ -299,6 +343,23 @@ func TestLoadConfigration(t *testing.T) {
 				OmitDomain: pbool(false),
 			},
 		},
+		{
+			name:      "Endpoint",
+			localConf: nil,
+			globalConf: pstr(`---
+              default:
+                endpoint: http://127.0.0.1:8080/
+              blog1.example.com:
+                username: blog1
+                local_root: /data`),
+			blogKey: "blog1.example.com",
+			expect: blogConfig{
+				BlogID:    "blog1.example.com",
+				LocalRoot: "/data",
+				Username:  "blog1",
+				Endpoint:  "http://127.0.0.1:8080/",
+			},
+		},
 		{
 			name: "config that are only global will have the local flag false",
 			localConf: pstr(`---
@@ -326,6 +387,9 @@ func TestLoadConfigration(t *testing.T) {
 			if err != nil {
 				t.Fatal(err)
 			}
+			for k, v := range tc.env {
+				t.Setenv(k, v)
+			}
 			origPwd, err := os.Getwd()
 			if err != nil {
 				t.Fatal(err)
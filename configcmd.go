@@ -0,0 +1,259 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// secretConfigKeys are the keys whose values are masked by config show.
+var secretConfigKeys = map[string]bool{
+	"password":            true,
+	"consumer_secret":     true,
+	"access_token":        true,
+	"access_token_secret": true,
+}
+
+// defaultOnlyConfigKeys are the keys which are only effective in the default section.
+var defaultOnlyConfigKeys = map[string]bool{
+	"timestamp_source": true,
+	"git_unshallow":    true,
+}
+
+// configField is a key of blogConfig in the configuration files.
+type configField struct {
+	key   string
+	index int
+}
+
+// configFields returns the keys of blogConfig in the order of the fields.
+func configFields() []configField {
+	var fields []configField
+	t := reflect.TypeOf(blogConfig{})
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		key, _, _ := strings.Cut(f.Tag.Get("yaml"), ",")
+		if key == "-" {
+			continue
+		}
+		if key == "" {
+			key = strings.ToLower(f.Name)
+		}
+		fields = append(fields, configField{key: key, index: i})
+	}
+	return fields
+}
+
+// configLayer is one of the sources of the configuration of a blog.
+type configLayer struct {
+	source string
+	bc     *blogConfig
+}
+
+// configLayers returns the sources of the configuration of blogID in the order of
+// precedence, as they are merged by loadConfiguration.
+func configLayers(pwd, blogID string) ([]configLayer, error) {
+	var layers, defaults []configLayer
+	for _, e := range []struct {
+		name string
+		bc   func(v string) *blogConfig
+	}{
+		{"BLOGSYNC_USERNAME", func(v string) *blogConfig { return &blogConfig{Username: v} }},
+		{"BLOGSYNC_PASSWORD", func(v string) *blogConfig { return &blogConfig{Password: v} }},
+		{"BLOGSYNC_ENDPOINT", func(v string) *blogConfig { return &blogConfig{Endpoint: v} }},
+	} {
+		if e.name != "BLOGSYNC_ENDPOINT" {
+			name := blogEnvName(e.name, blogID)
+			if v := os.Getenv(name); v != "" {
+				layers = append(layers, configLayer{"$" + name, e.bc(v)})
+			}
+		}
+		if v := os.Getenv(e.name); v != "" {
+			defaults = append(defaults, configLayer{"$" + e.name, e.bc(v)})
+		}
+	}
+
+	for _, f := range configFiles(pwd) {
+		conf, err := loadSingleConfigFile(f)
+		if err != nil {
+			return nil, err
+		}
+		if conf == nil {
+			continue
+		}
+		if bc := conf.Blogs[blogID]; bc != nil {
+			layers = append(layers, configLayer{f, bc})
+		}
+		defaults = append(defaults, configLayer{f + " (default)", conf.Default})
+	}
+
+	tokens, err := loadStoredTokens()
+	if err != nil {
+		return nil, err
+	}
+	if t := tokens[blogID]; t != nil {
+		p, _ := tokenStorePath()
+		layers = append(layers, configLayer{p, &blogConfig{
+			AccessToken:       t.AccessToken,
+			AccessTokenSecret: t.AccessTokenSecret,
+		}})
+	}
+	return append(layers, defaults...), nil
+}
+
+// showBlogConfig writes the effective configuration of the blog to w as YAML with
+// the source of each value in the comment. The secrets are masked.
+func showBlogConfig(w io.Writer, pwd string, bc *blogConfig) error {
+	layers, err := configLayers(pwd, bc.BlogID)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "%s:\n", bc.BlogID)
+	v := reflect.ValueOf(bc).Elem()
+	for _, f := range configFields() {
+		fv := v.Field(f.index)
+		if fv.IsZero() {
+			continue
+		}
+		source := "unknown"
+		for _, l := range layers {
+			lv := reflect.ValueOf(l.bc).Elem().Field(f.index)
+			if !lv.IsZero() && reflect.DeepEqual(lv.Interface(), fv.Interface()) {
+				source = l.source
+				break
+			}
+		}
+		var value interface{} = reflect.Indirect(fv).Interface()
+		if secretConfigKeys[f.key] {
+			value = "********"
+		}
+		bb, err := yaml.Marshal(value)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(w, "  %s: %s  # %s\n", f.key, strings.TrimSpace(string(bb)), source)
+	}
+	return nil
+}
+
+// configKeyLine returns the line of key in the section of blogID in the YAML
+// configuration, or 0 if it is not found.
+func configKeyLine(content, blogID, key string) int {
+	sectionReg := regexp.MustCompile(`^["']?` + regexp.QuoteMeta(blogID) + `["']?\s*:`)
+	keyReg := regexp.MustCompile(`^\s+["']?` + regexp.QuoteMeta(key) + `["']?\s*:`)
+	inSection := false
+	for i, l := range strings.Split(content, "\n") {
+		if l != "" && l[0] != ' ' && l[0] != '\t' && l[0] != '#' {
+			inSection = sectionReg.MatchString(l)
+			continue
+		}
+		if inSection && keyReg.MatchString(l) {
+			return i + 1
+		}
+	}
+	return 0
+}
+
+// validateConfigFile reports the unknown keys and the keys in the wrong section
+// in the configuration file at path.
+func validateConfigFile(path string) ([]*diagnostic, error) {
+	bb, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var sections map[string]yaml.MapSlice
+	if err := yaml.Unmarshal(bb, &sections); err != nil {
+		// The syntax error is reported on loading the configuration
+		return nil, nil
+	}
+
+	known := map[string]bool{}
+	var keys []string
+	for _, f := range configFields() {
+		known[f.key] = true
+		keys = append(keys, f.key)
+	}
+	blogIDs := make([]string, 0, len(sections))
+	for blogID := range sections {
+		blogIDs = append(blogIDs, blogID)
+	}
+	sort.Strings(blogIDs)
+
+	var ds []*diagnostic
+	for _, blogID := range blogIDs {
+		for _, item := range sections[blogID] {
+			key := fmt.Sprint(item.Key)
+			d := &diagnostic{path: path, line: configKeyLine(string(bb), blogID, key)}
+			switch {
+			case !known[key]:
+				d.message = fmt.Sprintf("unknown key %q in %s", key, blogID)
+				if k := similarKey(key, keys); k != "" {
+					d.message += fmt.Sprintf(", did you mean %q?", k)
+				}
+			case defaultOnlyConfigKeys[key] && blogID != "default":
+				d.warning = true
+				d.message = fmt.Sprintf("%s in %s is ignored, it is only effective in default", key, blogID)
+			default:
+				continue
+			}
+			ds = append(ds, d)
+		}
+	}
+	return ds, nil
+}
+
+// validateBlogConfig reports the problems of the effective configuration of the
+// blog, such as missing credentials and the local root.
+func validateBlogConfig(bc *blogConfig) []*diagnostic {
+	var ds []*diagnostic
+	report := func(warning bool, format string, args ...interface{}) {
+		ds = append(ds, &diagnostic{path: bc.BlogID, warning: warning, message: fmt.Sprintf(format, args...)})
+	}
+
+	switch bc.authMethod() {
+	case authOAuth1:
+		if bc.ConsumerKey == "" || bc.ConsumerSecret == "" {
+			report(false, "consumer_key and consumer_secret are required for auth %s", authOAuth1)
+		}
+		if bc.AccessToken == "" || bc.AccessTokenSecret == "" {
+			report(false, "no access token, run `blogsync auth login %s`", bc.BlogID)
+		}
+	default:
+		if bc.Username == "" {
+			report(false, "username is not set")
+		}
+		if bc.Password == "" && bc.PasswordCommand == "" {
+			report(false, "neither password nor password_command is set")
+		}
+	}
+
+	if bc.LocalRoot == "" {
+		report(true, "local_root is not set")
+	} else if fi, err := os.Stat(bc.LocalRoot); err != nil || !fi.IsDir() {
+		report(false, "local_root does not exist: %s", bc.LocalRoot)
+	}
+
+	switch bc.ConflictStyle {
+	case "", conflictStyleMarkers, conflictStyleSidecar:
+	default:
+		report(false, "conflict_style must be %s or %s: %s", conflictStyleMarkers, conflictStyleSidecar, bc.ConflictStyle)
+	}
+	switch bc.ImageSyntax {
+	case "", imageSyntaxFotolife, imageSyntaxURL:
+	default:
+		report(false, "image_syntax must be %s or %s: %s", imageSyntaxFotolife, imageSyntaxURL, bc.ImageSyntax)
+	}
+	return ds
+}
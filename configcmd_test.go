@@ -0,0 +1,79 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestConfigShow(t *testing.T) {
+	_, dir := setupFakeBlog(t)
+	globalConf := filepath.Join(dir, ".config", "blogsync", "config.yaml")
+	if err := os.MkdirAll(filepath.Dir(globalConf), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(globalConf, []byte("default:\n  conflict_style: sidecar\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv(blogEnvName("BLOGSYNC_PASSWORD", fakeBlogID), fakeAPIKey)
+
+	out, err := blogsyncApp(newApp())("config", "show", fakeBlogID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	localConf := filepath.Join(dir, "blogsync.yaml")
+	for _, expect := range []string{
+		fakeBlogID + ":",
+		"  local_root: " + dir + "  # " + localConf,
+		"  username: " + fakeUsername + "  # " + localConf,
+		"  password: '********'  # $BLOGSYNC_PASSWORD_BLOGSYNCTEST_HATENABLOG_COM",
+		"  omit_domain: true  # " + localConf,
+		"  conflict_style: sidecar  # " + globalConf + " (default)",
+	} {
+		if !strings.Contains(out+"\n", expect+"\n") {
+			t.Errorf("output should contain %q:\n%s", expect, out)
+		}
+	}
+	if strings.Contains(out, fakeAPIKey) {
+		t.Errorf("password should be masked:\n%s", out)
+	}
+
+	if _, err := blogsyncApp(newApp())("config", "show", "unknown.example.com"); err == nil {
+		t.Errorf("unknown blog should be an error")
+	}
+}
+
+func TestConfigValidate(t *testing.T) {
+	_, dir := setupFakeBlog(t)
+	blogsync := blogsyncApp(newApp())
+	out, err := blogsync("config", "validate")
+	if err != nil || out != "" {
+		t.Fatalf("valid configuration should have no problems: %v\n%s", err, out)
+	}
+
+	localConf := filepath.Join(dir, "blogsync.yaml")
+	if err := appendFile(localConf, strings.Join([]string{
+		"  pasword: apikey",
+		"  timestamp_source: mtime",
+		"other.example.com:",
+		"  local_root: ./missing",
+		"",
+	}, "\n")); err != nil {
+		t.Fatal(err)
+	}
+	out, err = blogsync("config", "validate")
+	if err == nil || err.Error() != "4 problem(s) found" {
+		t.Errorf("problems should be found: %v", err)
+	}
+	expect := strings.Join([]string{
+		localConf + `:7: unknown key "pasword" in ` + fakeBlogID + `, did you mean "password"?`,
+		localConf + ":8: warning: timestamp_source in " + fakeBlogID + " is ignored, it is only effective in default",
+		"other.example.com: username is not set",
+		"other.example.com: neither password nor password_command is set",
+		"other.example.com: local_root does not exist: " + filepath.Join(dir, "missing"),
+	}, "\n")
+	if out != expect {
+		t.Errorf("got:\n%s\nwant:\n%s", out, expect)
+	}
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+)
+
+const (
+	conflictStyleMarkers = "markers"
+	conflictStyleSidecar = "sidecar"
+
+	sidecarSuffix = ".remote"
+)
+
+var conflictMarkerReg = regexp.MustCompile(`(?m)^<<<<<<< local$`)
+
+func hasConflictMarkers(content string) bool {
+	return conflictMarkerReg.MatchString(content)
+}
+
+// sidecarPath returns the path of the file in which the remote content is written
+// on conflict, such as "entry/foo.remote.md" for "entry/foo.md".
+func sidecarPath(path string) string {
+	ext := entryFileExt(path)
+	return strings.TrimSuffix(path, ext) + sidecarSuffix + ext
+}
+
+func isSidecar(path string) bool {
+	return strings.HasSuffix(trimEntryExt(path), sidecarSuffix)
+}
+
+// mergeWithMarkers merges local and remote line by line, wrapping the differing
+// lines with git-style conflict markers.
+func mergeWithMarkers(local, remote string) string {
+	buf := &strings.Builder{}
+	var localLines, remoteLines []string
+	flush := func() {
+		if len(localLines) == 0 && len(remoteLines) == 0 {
+			return
+		}
+		buf.WriteString("<<<<<<< local\n")
+		for _, l := range localLines {
+			buf.WriteString(l)
+		}
+		buf.WriteString("=======\n")
+		for _, l := range remoteLines {
+			buf.WriteString(l)
+		}
+		buf.WriteString(">>>>>>> remote\n")
+		localLines, remoteLines = nil, nil
+	}
+	withNewline := func(l string) string {
+		if !strings.HasSuffix(l, "\n") {
+			return l + "\n"
+		}
+		return l
+	}
+	for _, op := range diffLines(splitLines(remote), splitLines(local)) {
+		switch op.kind {
+		case '-':
+			remoteLines = append(remoteLines, withNewline(op.line))
+		case '+':
+			localLines = append(localLines, withNewline(op.line))
+		default:
+			flush()
+			buf.WriteString(op.line)
+		}
+	}
+	flush()
+	return buf.String()
+}
+
+// writeConflict leaves the remote entry re for the local file at path, which has
+// been changed on both sides, in the way of the conflict_style configuration. The
+// remote revision is recorded as synced, so that the resolved file can be pushed.
+// Until then push refuses the file while it has the markers or the sidecar.
+func (b *broker) writeConflict(path string, re *entry) error {
+	bb, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	remote := b.fileContent(re, path)
+
+	sidecar := b.conflictStyle() == conflictStyleSidecar
+	dest, content := path, mergeWithMarkers(string(bb), remote)
+	if sidecar {
+		dest, content = sidecarPath(path), remote
+	}
+	if err := b.writeFile(dest, []byte(content)); err != nil {
+		return err
+	}
+	if dryRun {
+		return nil
+	}
+	if sidecar {
+		b.logf("conflict", "both local and remote have been changed, remote is written to %s", dest)
+	} else {
+		fileModified(path)
+		b.logf("conflict", "both local and remote have been changed, resolve conflicts in %s", path)
+	}
+	b.recordSync(re, path, []byte(remote))
+	return nil
+}
+
+func errConflict(path string) error {
+	return fmt.Errorf("conflict: both local and remote have been changed: %s", path)
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/x-motemen/blogsync/atom"
+)
+
+func TestMergeWithMarkers(t *testing.T) {
+	local := "a\nb\nc\nd\n"
+	remote := "a\nB\nc\nd\ne"
+	expect := `a
+<<<<<<< local
+b
+=======
+B
+>>>>>>> remote
+c
+d
+<<<<<<< local
+=======
+e
+>>>>>>> remote
+`
+	if got := mergeWithMarkers(local, remote); got != expect {
+		t.Errorf("got:\n%s\nexpect:\n%s", got, expect)
+	}
+	if !hasConflictMarkers(expect) {
+		t.Errorf("conflict markers should be detected")
+	}
+}
+
+func TestConflict(t *testing.T) {
+	setup := func(t *testing.T, conflictStyle string) (func(...string) (string, error), string, func()) {
+		s, dir := setupFakeBlog(t)
+		if conflictStyle != "" {
+			if err := appendFile(filepath.Join(dir, "blogsync.yaml"), "  conflict_style: "+conflictStyle+"\n"); err != nil {
+				t.Fatal(err)
+			}
+		}
+		editURL := addFakeAtomEntry(t, s, &atom.Entry{
+			Title:     "conflict",
+			Content:   atom.Content{Content: "line1\nline2\n"},
+			CustomURL: "conflict",
+		})
+		blogsync := blogsyncApp(newApp())
+		if _, err := blogsync("pull"); err != nil {
+			t.Fatal(err)
+		}
+		entryFile := filepath.Join(dir, "entry", "conflict.md")
+		if err := appendFile(entryFile, "local\n"); err != nil {
+			t.Fatal(err)
+		}
+		editRemote := func() {
+			s.Now = func() time.Time { return time.Now().Add(time.Hour) }
+			s.UpdateEntry(editURL, &atom.Entry{
+				Title:   "conflict",
+				Content: atom.Content{Content: "line1\nline2\nremote\n"},
+			})
+		}
+		return blogsync, entryFile, editRemote
+	}
+
+	t.Run("only local is changed", func(t *testing.T) {
+		blogsync, entryFile, _ := setup(t, "")
+		// Make the local file look older than remote, which is ignored on pull
+		// because the last synced revision is recorded.
+		old := time.Date(2006, 1, 2, 15, 4, 5, 0, time.UTC)
+		if err := os.Chtimes(entryFile, old, old); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := blogsync("pull"); err != nil {
+			t.Fatal(err)
+		}
+		bb, _ := os.ReadFile(entryFile)
+		if !strings.HasSuffix(string(bb), "local\n") {
+			t.Errorf("local change is overwritten:\n%s", string(bb))
+		}
+	})
+
+	t.Run("push with markers", func(t *testing.T) {
+		blogsync, entryFile, editRemote := setup(t, "")
+		editRemote()
+
+		out, err := blogsync("status", "--json")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(out, `"status": "conflict"`) {
+			t.Errorf("conflict should be reported: %s", out)
+		}
+
+		if _, err := blogsync("push", entryFile); err == nil || !strings.Contains(err.Error(), "conflict") {
+			t.Errorf("conflict error should be occurred but: %v", err)
+		}
+		bb, _ := os.ReadFile(entryFile)
+		if !strings.Contains(string(bb), "<<<<<<< local\nlocal\n=======\nremote\n>>>>>>> remote\n") {
+			t.Errorf("conflict markers are not written:\n%s", string(bb))
+		}
+
+		if _, err := blogsync("push", entryFile); err == nil || !strings.Contains(err.Error(), "conflict markers") {
+			t.Errorf("push should be refused while markers remain but: %v", err)
+		}
+
+		resolved := strings.Replace(string(bb), "<<<<<<< local\nlocal\n=======\nremote\n>>>>>>> remote\n", "local\nremote\n", 1)
+		if err := os.WriteFile(entryFile, []byte(resolved), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := blogsync("push", entryFile); err != nil {
+			t.Errorf("resolved file should be pushed but: %s", err)
+		}
+	})
+
+	t.Run("push with sidecar", func(t *testing.T) {
+		blogsync, entryFile, editRemote := setup(t, conflictStyleSidecar)
+		editRemote()
+
+		if _, err := blogsync("push", entryFile); err == nil || !strings.Contains(err.Error(), "conflict") {
+			t.Errorf("conflict error should be occurred but: %v", err)
+		}
+		sidecar := sidecarPath(entryFile)
+		remote, err := os.ReadFile(sidecar)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := blogsync("push", entryFile); err == nil || !strings.Contains(err.Error(), "unresolved conflict") {
+			t.Errorf("push should be refused while the sidecar remains but: %v", err)
+		}
+		if _, err := blogsync("push", sidecar); err == nil || !strings.Contains(err.Error(), "cannot be pushed") {
+			t.Errorf("push of the sidecar should be refused but: %v", err)
+		}
+		bb, err := os.ReadFile(entryFile)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if bb, _ := os.ReadFile(sidecar); string(bb) != string(remote) {
+			t.Errorf("sidecar should not be changed:\n%s", string(bb))
+		}
+
+		if err := os.WriteFile(entryFile, []byte(string(bb)+"remote\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Remove(sidecar); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := blogsync("push", entryFile); err != nil {
+			t.Errorf("resolved file should be pushed but: %s", err)
+		}
+	})
+
+	t.Run("pull with sidecar", func(t *testing.T) {
+		blogsync, entryFile, editRemote := setup(t, conflictStyleSidecar)
+		editRemote()
+
+		if _, err := blogsync("pull"); err != nil {
+			t.Fatal(err)
+		}
+		bb, _ := os.ReadFile(entryFile)
+		if !strings.HasSuffix(string(bb), "line2\nlocal\n") {
+			t.Errorf("local file should be kept:\n%s", string(bb))
+		}
+		sidecar, err := os.ReadFile(sidecarPath(entryFile))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.HasSuffix(string(sidecar), "line2\nremote\n") {
+			t.Errorf("remote content should be written in the sidecar:\n%s", string(sidecar))
+		}
+	})
+}
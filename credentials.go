@@ -0,0 +1,88 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"sync"
+)
+
+// blogEnvName returns the name of the environment variable of the blog, which
+// is name followed by the blog ID in upper case with non-alphanumeric characters
+// replaced with "_", such as BLOGSYNC_PASSWORD_MOTEMEN_HATENABLOG_COM.
+func blogEnvName(name, blogID string) string {
+	suffix := strings.Map(func(r rune) rune {
+		switch {
+		case 'a' <= r && r <= 'z':
+			return r - 'a' + 'A'
+		case 'A' <= r && r <= 'Z', '0' <= r && r <= '9':
+			return r
+		}
+		return '_'
+	}, blogID)
+	return name + "_" + suffix
+}
+
+// applyBlogEnv overrides the credentials of each blog with BLOGSYNC_USERNAME_<blog>
+// and BLOGSYNC_PASSWORD_<blog>, which take precedence over the configuration files.
+func (c *config) applyBlogEnv() {
+	for blogID, bc := range c.Blogs {
+		if v := os.Getenv(blogEnvName("BLOGSYNC_USERNAME", blogID)); v != "" {
+			bc.Username = v
+		}
+		if v := os.Getenv(blogEnvName("BLOGSYNC_PASSWORD", blogID)); v != "" {
+			bc.Password = v
+		}
+	}
+}
+
+// passwordCommands caches the output of password_command, so that each command
+// runs at most once in the process.
+var passwordCommands = struct {
+	sync.Mutex
+	m map[string]string
+}{m: map[string]string{}}
+
+// runPasswordCommand runs command with the shell and returns the first line of
+// the output. The standard input and error are passed through, so that the
+// command can prompt the user.
+func runPasswordCommand(command string) (string, error) {
+	passwordCommands.Lock()
+	defer passwordCommands.Unlock()
+	if p, ok := passwordCommands.m[command]; ok {
+		return p, nil
+	}
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.Command("cmd", "/c", command)
+	} else {
+		cmd = exec.Command("sh", "-c", command)
+	}
+	cmd.Stdin, cmd.Stderr = os.Stdin, os.Stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return "", err
+	}
+	p, _, _ := strings.Cut(string(out), "\n")
+	p = strings.TrimSuffix(p, "\r")
+	if p == "" {
+		return "", fmt.Errorf("no output")
+	}
+	passwordCommands.m[command] = p
+	return p, nil
+}
+
+// password returns the API key of the blog. It is the output of password_command
+// unless password is set.
+func (bc *blogConfig) password() (string, error) {
+	if bc.Password != "" || bc.PasswordCommand == "" {
+		return bc.Password, nil
+	}
+	p, err := runPasswordCommand(bc.PasswordCommand)
+	if err != nil {
+		return "", fmt.Errorf("password_command of %s failed: %w", bc.BlogID, err)
+	}
+	return p, nil
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestBlogEnvName(t *testing.T) {
+	testCases := []struct {
+		blogID string
+		expect string
+	}{
+		{"motemen.hatenablog.com", "BLOGSYNC_PASSWORD_MOTEMEN_HATENABLOG_COM"},
+		{"blog-1.example.jp", "BLOGSYNC_PASSWORD_BLOG_1_EXAMPLE_JP"},
+	}
+	for _, tc := range testCases {
+		if got := blogEnvName("BLOGSYNC_PASSWORD", tc.blogID); got != tc.expect {
+			t.Errorf("blogEnvName(%q) = %q, want %q", tc.blogID, got, tc.expect)
+		}
+	}
+}
+
+func TestPasswordCommand(t *testing.T) {
+	s, dir := setupFakeBlog(t)
+	addFakeEntry(t, s, "credentials", "credentials")
+	confFile := filepath.Join(dir, "blogsync.yaml")
+	bb, err := os.ReadFile(confFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	setPasswordCommand := func(command string) {
+		conf := strings.Replace(string(bb), "password: "+fakeAPIKey, "password_command: "+command, 1)
+		if err := os.WriteFile(confFile, []byte(conf), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	blogsync := blogsyncApp(newApp())
+
+	t.Log("password_command fails the API requests with its error")
+	setPasswordCommand("exit 3")
+	if _, err := blogsync("pull"); err == nil || !strings.Contains(err.Error(), "password_command") {
+		t.Errorf("error of password_command should be returned but: %v", err)
+	}
+
+	t.Log("password_command does not run without API requests")
+	marker := filepath.Join(dir, "password_command_ran")
+	setPasswordCommand("touch " + marker + " && echo " + fakeAPIKey)
+	if _, err := blogsync("lint"); err != nil {
+		t.Fatal(err)
+	}
+	if exists(marker) {
+		t.Errorf("password_command should not run on lint")
+	}
+
+	if _, err := blogsync("pull"); err != nil {
+		t.Fatal(err)
+	}
+	if !exists(filepath.Join(dir, "entry", "credentials.md")) {
+		t.Errorf("entry should be pulled with the password by the command")
+	}
+	if !exists(marker) {
+		t.Errorf("password_command should run on pull")
+	}
+}
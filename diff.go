@@ -0,0 +1,183 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"strings"
+)
+
+const diffContextLines = 3
+
+type diffOp struct {
+	kind byte // ' ', '-' or '+'
+	line string
+}
+
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	lines := strings.SplitAfter(s, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// diffLines computes the shortest edit script from a to b based on the longest
+// common subsequence. Entries are small enough for the quadratic algorithm.
+func diffLines(a, b []string) []diffOp {
+	n, m := len(a), len(b)
+	lcs := make([][]int32, n+1)
+	for i := range lcs {
+		lcs[i] = make([]int32, m+1)
+	}
+	for i := n - 1; i >= 0; i-- {
+		for j := m - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	ops := make([]diffOp, 0, n+m)
+	i, j := 0, 0
+	for i < n && j < m {
+		switch {
+		case a[i] == b[j]:
+			ops = append(ops, diffOp{' ', a[i]})
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			ops = append(ops, diffOp{'-', a[i]})
+			i++
+		default:
+			ops = append(ops, diffOp{'+', b[j]})
+			j++
+		}
+	}
+	for ; i < n; i++ {
+		ops = append(ops, diffOp{'-', a[i]})
+	}
+	for ; j < m; j++ {
+		ops = append(ops, diffOp{'+', b[j]})
+	}
+	return ops
+}
+
+func hunkRange(start, count int) string {
+	if count == 0 {
+		return fmt.Sprintf("%d,0", start)
+	}
+	if count == 1 {
+		return fmt.Sprintf("%d", start+1)
+	}
+	return fmt.Sprintf("%d,%d", start+1, count)
+}
+
+// unifiedDiff returns the unified diff from a to b, or "" if they are the same.
+func unifiedDiff(aName, bName, a, b string) string {
+	if a == b {
+		return ""
+	}
+	ops := diffLines(splitLines(a), splitLines(b))
+
+	buf := &strings.Builder{}
+	fmt.Fprintf(buf, "--- %s\n+++ %s\n", aName, bName)
+
+	// aPos and bPos are the numbers of lines consumed before ops[k]
+	aPos := make([]int, len(ops)+1)
+	bPos := make([]int, len(ops)+1)
+	for k, op := range ops {
+		aPos[k+1], bPos[k+1] = aPos[k], bPos[k]
+		if op.kind != '+' {
+			aPos[k+1]++
+		}
+		if op.kind != '-' {
+			bPos[k+1]++
+		}
+	}
+
+	for k := 0; k < len(ops); {
+		if ops[k].kind == ' ' {
+			k++
+			continue
+		}
+		start := max(0, k-diffContextLines)
+		end := k
+		for end < len(ops) {
+			if ops[end].kind != ' ' {
+				end++
+				continue
+			}
+			// look ahead whether the next change is close enough to be merged
+			next := end
+			for next < len(ops) && ops[next].kind == ' ' {
+				next++
+			}
+			if next == len(ops) || next-end > 2*diffContextLines {
+				end = min(len(ops), end+diffContextLines)
+				break
+			}
+			end = next
+		}
+
+		fmt.Fprintf(buf, "@@ -%s +%s @@\n",
+			hunkRange(aPos[start], aPos[end]-aPos[start]),
+			hunkRange(bPos[start], bPos[end]-bPos[start]))
+		for _, op := range ops[start:end] {
+			buf.WriteByte(op.kind)
+			buf.WriteString(op.line)
+			if !strings.HasSuffix(op.line, "\n") {
+				buf.WriteString("\n\\ No newline at end of file\n")
+			}
+		}
+		k = end
+	}
+	return buf.String()
+}
+
+// splitFrontMatter splits the content of an entry file into the front matter
+// including the delimiters and the body.
+func splitFrontMatter(content string) (frontMatter, body string) {
+	if !strings.HasPrefix(content, "---\n") {
+		return "", content
+	}
+	loc := delimReg.FindAllStringIndex(content, 2)
+	if len(loc) != 2 {
+		return "", content
+	}
+	return content[:loc[1][0]+len("---\n")], content[loc[1][1]:]
+}
+
+// DiffEntry returns the unified diffs of the front matter and the body from the
+// remote entry to the local file at path. It returns "" if there is no difference.
+// A file which has not been posted yet is compared with empty content.
+func (b *broker) DiffEntry(ctx context.Context, path, name string) (string, error) {
+	bb, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	e, err := entryFromReader(bytes.NewReader(bb))
+	if err != nil {
+		return "", err
+	}
+	var remote string
+	if e.EditURL != "" {
+		re, err := b.fetchEntry(ctx, path, e.EditURL)
+		if err != nil {
+			return "", err
+		}
+		remote = b.fileContent(re, path)
+	}
+
+	remoteFrontMatter, remoteBody := splitFrontMatter(remote)
+	localFrontMatter, localBody := splitFrontMatter(string(bb))
+	return unifiedDiff(name+" (remote front matter)", name+" (local front matter)",
+		remoteFrontMatter, localFrontMatter) +
+		unifiedDiff(name+" (remote)", name+" (local)", remoteBody, localBody), nil
+}
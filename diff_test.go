@@ -0,0 +1,115 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/x-motemen/blogsync/atom"
+)
+
+func TestUnifiedDiff(t *testing.T) {
+	testCases := []struct {
+		name   string
+		a, b   string
+		expect string
+	}{
+		{
+			name:   "same",
+			a:      "a\nb\n",
+			b:      "a\nb\n",
+			expect: "",
+		},
+		{
+			name: "modified",
+			a:    "1\n2\n3\n4\n5\n6\n7\n8\n9\n",
+			b:    "1\n2\n3\n4\nfive\n6\n7\n8\n9\n",
+			expect: `--- a
++++ b
+@@ -2,7 +2,7 @@
+ 2
+ 3
+ 4
+-5
++five
+ 6
+ 7
+ 8
+`,
+		},
+		{
+			name: "separate hunks",
+			a:    "1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n",
+			b:    "0\n1\n2\n3\n4\n5\n6\n7\n8\n9\n",
+			expect: `--- a
++++ b
+@@ -1,3 +1,4 @@
++0
+ 1
+ 2
+ 3
+@@ -7,4 +8,3 @@
+ 7
+ 8
+ 9
+-10
+`,
+		},
+		{
+			name: "from empty",
+			a:    "",
+			b:    "a\nb",
+			expect: `--- a
++++ b
+@@ -0,0 +1,2 @@
++a
++b
+\ No newline at end of file
+`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := unifiedDiff("a", "b", tc.a, tc.b)
+			if got != tc.expect {
+				t.Errorf("got:\n%s\nexpect:\n%s", got, tc.expect)
+			}
+		})
+	}
+}
+
+func TestDiff(t *testing.T) {
+	s, dir := setupFakeBlog(t)
+	addFakeAtomEntry(t, s, &atom.Entry{
+		Title:     "diff",
+		Content:   atom.Content{Content: "line1\nline2\n"},
+		CustomURL: "diff",
+	})
+
+	app := newApp()
+	blogsync := blogsyncApp(app)
+	if _, err := blogsync("pull"); err != nil {
+		t.Fatal(err)
+	}
+
+	entryFile := filepath.Join(dir, "entry", "diff.md")
+	out, err := blogsync("diff", entryFile)
+	if err != nil {
+		t.Fatalf("no differences should be found but: %s, %s", err, out)
+	}
+	if out != "" {
+		t.Errorf("output should be empty but: %s", out)
+	}
+
+	if err := appendFile(entryFile, "line3\n"); err != nil {
+		t.Fatal(err)
+	}
+	out, err = blogsync("diff", entryFile)
+	if err != errDiffFound {
+		t.Errorf("errDiffFound should be returned but: %v", err)
+	}
+	if !strings.Contains(out, "+line3") || strings.Contains(out, "front matter)") {
+		t.Errorf("unexpected diff:\n%s", out)
+	}
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+)
+
+// dryRun is set by --dry-run flag. The requests changing remote entries and the
+// writes to local files are replaced with the plan printed by broker.plan.
+var dryRun bool
+
+// plan prints what would be done in the dry run mode.
+func (b *broker) plan(format string, args ...interface{}) {
+	fmt.Fprintf(b.writer, "[dry-run] "+format+"\n", args...)
+}
+
+func (b *broker) writeFile(path string, content []byte) error {
+	if dryRun {
+		b.plan("write %s", path)
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, content, 0666)
+}
+
+// removeFile removes the file at path if it exists.
+func (b *broker) removeFile(path string) error {
+	if !fileExists(path) {
+		return nil
+	}
+	if dryRun {
+		b.plan("delete %s", path)
+		return nil
+	}
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+func (b *broker) moveFile(src, dest string) error {
+	if dryRun {
+		b.plan("move %s -> %s", src, dest)
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+		return err
+	}
+	return os.Rename(src, dest)
+}
+
+// dryRunEntry returns the entry which the API would return for e sent to it, as
+// far as it can be guessed without sending. The URL follows CustomPath, and is
+// left unknown for new entries without CustomPath.
+func (b *broker) dryRunEntry(e *entry, isPage bool) *entry {
+	eh := *e.entryHeader
+	if eh.CustomPath != "" {
+		p := "/" + eh.CustomPath
+		if !isPage {
+			var subdir string
+			if eh.URL != nil {
+				subdir, _ = b.blogConfig.extractURLEntryPath(eh.URL.Path)
+			}
+			p = subdir + b.blogConfig.entryDirectory() + eh.CustomPath
+		}
+		eh.URL = &entryURL{&url.URL{Path: p}}
+		eh.CustomPath = ""
+	}
+	return &entry{
+		entryHeader:  &eh,
+		LastModified: e.LastModified,
+		Content:      e.Content,
+		ContentType:  e.ContentType,
+	}
+}
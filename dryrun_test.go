@@ -0,0 +1,135 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDryRun(t *testing.T) {
+	s, dir := setupFakeBlog(t)
+	editURL := addFakeEntry(t, s, "dry-run", "dry-run")
+
+	blogsync := blogsyncApp(newApp())
+	entryFile := filepath.Join(dir, "entry", "dry-run.md")
+	statePath := filepath.Join(dir, stateDir, "state.json")
+
+	t.Run("pull", func(t *testing.T) {
+		out, err := blogsync("--dry-run", "pull")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if expect := "[dry-run] write " + entryFile; out != expect {
+			t.Errorf("got %q, want %q", out, expect)
+		}
+		if exists(entryFile) || exists(statePath) {
+			t.Errorf("no files should be written")
+		}
+		if _, err := blogsync("pull"); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	state, err := os.ReadFile(statePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("push", func(t *testing.T) {
+		bb, err := os.ReadFile(entryFile)
+		if err != nil {
+			t.Fatal(err)
+		}
+		content := strings.Replace(string(bb), "CustomPath: dry-run\n", "", 1) + "updated\n"
+		if err := os.WriteFile(entryFile, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		movedFile := filepath.Join(dir, "entry", "moved.md")
+		if err := os.Rename(entryFile, movedFile); err != nil {
+			t.Fatal(err)
+		}
+		defer os.Rename(movedFile, entryFile)
+
+		out, err := blogsync("--dry-run", "push", movedFile)
+		if err != nil {
+			t.Fatal(err)
+		}
+		expect := strings.Join([]string{
+			"[dry-run] PUT " + editURL,
+			"[dry-run] write " + movedFile,
+		}, "\n")
+		if out != expect {
+			t.Errorf("got:\n%s\nwant:\n%s", out, expect)
+		}
+		if g := s.Entry(editURL).Content.Content; g != "dry-run\n" {
+			t.Errorf("remote should not be changed: %q", g)
+		}
+	})
+
+	t.Run("post", func(t *testing.T) {
+		app := newApp()
+		app.Reader = strings.NewReader("---\nTitle: new\n---\n\nnew\n")
+		blogsync := blogsyncApp(app)
+		out, err := blogsync("--dry-run", "post", "--custom-path", "new", fakeBlogID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		expect := strings.Join([]string{
+			"[dry-run] POST " + s.Endpoint() + fakeUsername + "/" + fakeBlogID + "/atom/entry",
+			"[dry-run] write " + filepath.Join(dir, "entry", "new.md"),
+		}, "\n")
+		if out != expect {
+			t.Errorf("got:\n%s\nwant:\n%s", out, expect)
+		}
+		if n := len(s.Entries()); n != 1 {
+			t.Errorf("no entries should be posted: %d", n)
+		}
+	})
+
+	t.Run("remove", func(t *testing.T) {
+		out, err := blogsync("--dry-run", "remove", entryFile)
+		if err != nil {
+			t.Fatal(err)
+		}
+		expect := strings.Join([]string{
+			"[dry-run] write " + filepath.Join(dir, stateDir, trashDir, filepath.Base(editURL)+".xml"),
+			"[dry-run] write " + filepath.Join(dir, stateDir, trashDir, filepath.Base(editURL)+".md"),
+			"[dry-run] DELETE " + editURL,
+			"[dry-run] delete " + entryFile,
+		}, "\n")
+		if out != expect {
+			t.Errorf("got:\n%s\nwant:\n%s", out, expect)
+		}
+		if s.Entry(editURL) == nil || !exists(entryFile) || exists(filepath.Join(dir, stateDir, trashDir)) {
+			t.Errorf("nothing should be removed")
+		}
+	})
+
+	if bb, err := os.ReadFile(statePath); err != nil || string(bb) != string(state) {
+		t.Errorf("sync state should not be changed: %v", err)
+	}
+
+	t.Run("new", func(t *testing.T) {
+		newFile := filepath.Join(dir, "entry", "dry-run-new.md")
+		out, err := blogsync("--dry-run", "new", fakeBlogID, "dry-run-new")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if expect := "[dry-run] write " + newFile; out != expect {
+			t.Errorf("got %q, want %q", out, expect)
+		}
+		if exists(newFile) {
+			t.Errorf("%s should not be created", newFile)
+		}
+	})
+
+	t.Run("auth login", func(t *testing.T) {
+		if _, err := blogsync("--dry-run", "auth", "login", fakeBlogID); err == nil || !strings.Contains(err.Error(), "--dry-run") {
+			t.Errorf("auth login should be refused but: %v", err)
+		}
+		if p, _ := tokenStorePath(); exists(p) {
+			t.Errorf("%s should not be written", p)
+		}
+	})
+}
@@ -6,12 +6,11 @@ import (
 	"log"
 	"net/url"
 	"os"
-	"os/exec"
 	"path/filepath"
+	"reflect"
 	"regexp"
 	"runtime"
 	"strings"
-	"sync"
 	"time"
 
 	"github.com/x-motemen/blogsync/atom"
@@ -29,11 +28,151 @@ type entryHeader struct {
 	Category    []string   `yaml:"Category,omitempty"`
 	Date        *time.Time `yaml:"Date,omitempty"`
 	URL         *entryURL  `yaml:"URL,omitempty"`
-	EditURL     string     `yaml:"EditURL"`
+	EditURL     string     `yaml:"EditURL,omitempty"`
 	PreviewURL  string     `yaml:"PreviewURL,omitempty"`
 	IsDraft     bool       `yaml:"Draft,omitempty"`
 	IsScheduled bool       `yaml:"Scheduled,omitempty"`
 	CustomPath  string     `yaml:"CustomPath,omitempty"`
+	// Extra is the front matter keys unknown to blogsync in the original order,
+	// along with the known ones to put them back in their original positions.
+	// They are kept in local files but never sent to the API.
+	Extra []*frontMatterItem `yaml:"-"`
+}
+
+// entryHeaderKeys is the set of the front matter keys known to blogsync.
+var entryHeaderKeys = func() map[string]bool {
+	keys := map[string]bool{}
+	t := reflect.TypeOf(entryHeader{})
+	for i := 0; i < t.NumField(); i++ {
+		name, _, _ := strings.Cut(t.Field(i).Tag.Get("yaml"), ",")
+		if name != "" && name != "-" {
+			keys[name] = true
+		}
+	}
+	return keys
+}()
+
+// frontMatterItem is a top-level item of the front matter.
+type frontMatterItem struct {
+	Key   string
+	Value interface{}
+	// raw is the original text of the item, which is written back as is, so that
+	// the values are not changed by marshaling again, such as yes into true.
+	raw string
+	// known is true for the keys of entryHeader, which are written from the
+	// entryHeader itself.
+	known bool
+}
+
+// splitFrontMatterItems splits the front matter into the top-level items with their
+// text. Lines before the first key, such as comments, belong to the first item.
+func splitFrontMatterItems(frontMatter string) ([]*frontMatterItem, error) {
+	var chunks []string
+	head := ""
+	for _, line := range strings.SplitAfter(frontMatter, "\n") {
+		switch {
+		case line == "":
+		case !strings.ContainsAny(line[:1], " \t\r\n#-"):
+			chunks = append(chunks, head+line)
+			head = ""
+		case len(chunks) == 0:
+			head += line
+		default:
+			chunks[len(chunks)-1] += line
+		}
+	}
+
+	items := make([]*frontMatterItem, 0, len(chunks))
+	for _, c := range chunks {
+		var ms yaml.MapSlice
+		if err := yaml.Unmarshal([]byte(c), &ms); err != nil {
+			return nil, err
+		}
+		if len(ms) != 1 {
+			return nil, fmt.Errorf("unexpected item of front matter: %q", c)
+		}
+		if !strings.HasSuffix(c, "\n") {
+			c += "\n"
+		}
+		items = append(items, &frontMatterItem{Key: fmt.Sprint(ms[0].Key), Value: ms[0].Value, raw: c})
+	}
+	return items, nil
+}
+
+// extraHeaders returns the items of the front matter with the keys unknown to
+// blogsync marked, or nil if there are no unknown keys.
+func extraHeaders(frontMatter []byte) ([]*frontMatterItem, error) {
+	items, err := splitFrontMatterItems(string(frontMatter))
+	if err != nil {
+		// Fall back to marshaling each item, which may change the notation
+		var ms yaml.MapSlice
+		if err := yaml.Unmarshal(frontMatter, &ms); err != nil {
+			return nil, err
+		}
+		items = make([]*frontMatterItem, 0, len(ms))
+		for _, item := range ms {
+			bb, err := yaml.Marshal(yaml.MapSlice{item})
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, &frontMatterItem{Key: fmt.Sprint(item.Key), Value: item.Value, raw: string(bb)})
+		}
+	}
+	hasExtra := false
+	for _, item := range items {
+		item.known = entryHeaderKeys[item.Key]
+		hasExtra = hasExtra || !item.known
+	}
+	if !hasExtra {
+		return nil, nil
+	}
+	return items, nil
+}
+
+// mergeFrontMatter puts the unknown items of extra into the known front matter
+// marshaled from entryHeader. Each of them follows the known key which precedes
+// it in the original front matter, or the top if there is none.
+func mergeFrontMatter(known string, extra []*frontMatterItem) string {
+	items, err := splitFrontMatterItems(known)
+	if err != nil {
+		items = []*frontMatterItem{{raw: known}}
+	}
+	present := make(map[string]bool, len(items))
+	for _, item := range items {
+		present[item.Key] = true
+	}
+	following := map[string]string{}
+	anchor := ""
+	for _, item := range extra {
+		if item.known {
+			if present[item.Key] {
+				anchor = item.Key
+			}
+			continue
+		}
+		following[anchor] += item.raw
+	}
+
+	var sb strings.Builder
+	sb.WriteString(following[""])
+	for _, item := range items {
+		sb.WriteString(item.raw)
+		if item.Key != "" {
+			sb.WriteString(following[item.Key])
+		}
+	}
+	return sb.String()
+}
+
+// ExtraMap returns the unknown items of Extra as a map, which is handy in templates.
+func (eh *entryHeader) ExtraMap() map[string]interface{} {
+	m := make(map[string]interface{}, len(eh.Extra))
+	for _, item := range eh.Extra {
+		if !item.known {
+			m[item.Key] = item.Value
+		}
+	}
+	return m
 }
 
 func (eu *entryURL) MarshalYAML() (interface{}, error) {
@@ -54,14 +193,42 @@ func (eu *entryURL) UnmarshalYAML(unmarshal func(v interface{}) error) error {
 	return nil
 }
 
+// editURLPaths splits the path of EditURL into the segments before "atom" and
+// after it. The endpoint may be hosted anywhere and may have a path prefix, so
+// the last "atom" segment followed by "entry" or "page" is used as the anchor.
+func (eh *entryHeader) editURLPaths() (before, after []string) {
+	u, err := url.Parse(eh.EditURL)
+	if err != nil {
+		return nil, nil
+	}
+	paths := strings.Split(strings.Trim(u.Path, "/"), "/")
+	for i := len(paths) - 2; i >= 0; i-- {
+		if paths[i] == "atom" && (paths[i+1] == "entry" || paths[i+1] == "page") {
+			return paths[:i], paths[i+1:]
+		}
+	}
+	return nil, nil
+}
+
 func (eh *entryHeader) blogID() (string, error) {
 	// EditURL: https://blog.hatena.ne.jp/Songmu/songmu.hateblog.jp/atom/entry/...
 	// "songmu.hateblog.jp" is blogID in above case.
-	paths := strings.Split(eh.EditURL, "/")
-	if len(paths) < 5 {
+	before, _ := eh.editURLPaths()
+	if len(before) < 2 || before[len(before)-1] == "" {
 		return "", fmt.Errorf("failed to get blogID form EditURL: %s", eh.EditURL)
 	}
-	return paths[4], nil
+	return before[len(before)-1], nil
+}
+
+// entryID returns the ID of the entry at the tail of EditURL.
+// EditURL: https://blog.hatena.ne.jp/Songmu/songmu.hatenadiary.org/atom/entry/6801883189050452361
+// "6801883189050452361" is entryID in above case.
+func (eh *entryHeader) entryID() string {
+	_, after := eh.editURLPaths()
+	if len(after) != 2 {
+		return ""
+	}
+	return after[1]
 }
 
 func (eh *entryHeader) isBlogEntry() bool {
@@ -72,13 +239,71 @@ func (eh *entryHeader) isStaticPage() bool {
 	return strings.Contains(eh.EditURL, "/atom/page/")
 }
 
+const (
+	contentTypeMarkdown = "text/x-markdown"
+	contentTypeHatena   = "text/x-hatena-syntax"
+	contentTypeHTML     = "text/html"
+	contentTypePlain    = "text/plain"
+)
+
+// entryExt is the extension of entry files whose content type is unknown
+const entryExt = ".md"
+
+// entryExts maps the content types of entries to the extensions of entry files.
+var entryExts = []struct {
+	contentType, ext string
+}{
+	{contentTypeMarkdown, ".md"},
+	{contentTypeHatena, ".hatena"},
+	{contentTypeHTML, ".html"},
+	{contentTypePlain, ".txt"},
+}
+
+// extForContentType returns the extension of the entry file for the content type.
+func extForContentType(contentType string) string {
+	for _, ee := range entryExts {
+		if ee.contentType == contentType {
+			return ee.ext
+		}
+	}
+	return entryExt
+}
+
+// entryFileExt returns the extension of path if it is an entry file, or "".
+func entryFileExt(path string) string {
+	ext := filepath.Ext(path)
+	for _, ee := range entryExts {
+		if ee.ext == ext {
+			return ext
+		}
+	}
+	return ""
+}
+
+// contentTypeForPath returns the content type for the extension of path, or "".
+func contentTypeForPath(path string) string {
+	ext := filepath.Ext(path)
+	for _, ee := range entryExts {
+		if ee.ext == ext {
+			return ee.contentType
+		}
+	}
+	return ""
+}
+
+func trimEntryExt(path string) string {
+	return strings.TrimSuffix(path, entryFileExt(path))
+}
+
 // Entry is an entry stored on remote blog providers
 type entry struct {
 	*entryHeader
 	LastModified *time.Time
 	Content      string
 	ContentType  string
-	localPath    string
+	// FormattedContent is the HTML rendered by Hatena Blog
+	FormattedContent string
+	localPath        string
 }
 
 func (e *entry) HeaderString() string {
@@ -86,6 +311,9 @@ func (e *entry) HeaderString() string {
 	if err != nil {
 		log.Fatalf("error: %v", err)
 	}
+	if len(e.Extra) > 0 {
+		d = []byte(mergeFrontMatter(string(d), e.Extra))
+	}
 	headers := []string{
 		"---",
 		string(d),
@@ -106,6 +334,7 @@ func (e *entry) atom() *atom.Entry {
 	atomEntry := &atom.Entry{
 		Title: e.Title,
 		Content: atom.Content{
+			Type:    e.ContentType,
 			Content: e.Content,
 		},
 	}
@@ -184,7 +413,7 @@ func entryFromAtom(e *atom.Entry) (*entry, error) {
 		updated = nil
 	}
 
-	return &entry{
+	entry := &entry{
 		entryHeader: &entryHeader{
 			URL:         &entryURL{u},
 			EditURL:     editLink.Href,
@@ -198,7 +427,11 @@ func entryFromAtom(e *atom.Entry) (*entry, error) {
 		LastModified: e.Edited,
 		Content:      e.Content.Content,
 		ContentType:  e.Content.Type,
-	}, nil
+	}
+	if e.FormattedContent != nil {
+		entry.FormattedContent = e.FormattedContent.Content
+	}
+	return entry, nil
 }
 
 var delimReg = regexp.MustCompile(`---\n+`)
@@ -221,6 +454,9 @@ func entryFromReader(source io.Reader) (*entry, error) {
 		if err != nil {
 			return nil, err
 		}
+		if eh.Extra, err = extraHeaders([]byte(c[1])); err != nil {
+			return nil, err
+		}
 		// Set the updated to nil when the entry is still draft.
 		// But if the date is in the future, don't set to nil because it may be a reserved post.
 		// Also preserve the date if it's a scheduled post.
@@ -262,6 +498,7 @@ func entryFromFile(fpath string) (*entry, error) {
 		return nil, err
 	}
 	e.localPath = fpath
+	e.ContentType = contentTypeForPath(fpath)
 	return e, nil
 }
 
@@ -273,63 +510,23 @@ func asEntry(atomEntry *atom.Entry, err error) (*entry, error) {
 	return entryFromAtom(atomEntry)
 }
 
-var getGit = sync.OnceValue(func() func(...string) (string, error) {
-	g, err := exec.LookPath("git")
-	if err != nil || g == "" {
-		return nil
-	}
-	git := func(args ...string) (string, error) {
-		bb, err := exec.Command(g, args...).Output()
-		return strings.TrimSpace(string(bb)), err
-	}
-	if boolStr, err := git("rev-parse", "--is-shallow-repository"); err != nil {
-		return nil
-	} else if boolStr == "true" {
-		if _, err := git("fetch", "--unshallow"); err != nil {
-			return nil
-		}
-	}
-	return git
-})
-
-func modTime(fpath string) (time.Time, error) {
-	fi, err := os.Stat(fpath)
-	if err != nil {
-		return time.Time{}, err
-	}
-	ti := fi.ModTime()
-
-	if git := getGit(); git != nil {
-		// ref. https://git-scm.com/docs/pretty-formats#Documentation/pretty-formats.txt-emaiem
-		// %aI means "author date, strict ISO 8601 format"
-		timeStr, err := git("log", "-1", "--format=%aI", "--", fpath)
-		if err == nil && timeStr != "" {
-			// check if the file is clean (not modified) on git
-			out, err := git("status", "-s", "--", fpath)
-			// if the output is empty, it means the file has not been modified
-			if err == nil && out == "" {
-				t, err := time.Parse(time.RFC3339, timeStr)
-				if err == nil {
-					ti = t
-				}
-			}
-		}
-	}
-	return ti, nil
+func (bc *blogConfig) extractEntryPath(p string) (subdir string, entryPath string) {
+	subdir, entryPath = bc.extractURLEntryPath(p)
+	return subdir, trimEntryExt(entryPath)
 }
 
-func (bc *blogConfig) extractEntryPath(p string) (subdir string, entryPath string) {
+// extractURLEntryPath is like extractEntryPath but for the path of the entry URL,
+// which does not have the file extension.
+func (bc *blogConfig) extractURLEntryPath(p string) (subdir string, entryPath string) {
 	p = filepath.ToSlash(p)
 	entryDir := bc.entryDirectory()
 	if entryDir == "" {
-		entryPath = strings.TrimSuffix(p, entryExt)
-		return "", entryPath
+		return "", p
 	}
 
 	stuffs := strings.SplitN(p, entryDir, 2)
 	if len(stuffs) != 2 {
 		return "", ""
 	}
-	entryPath = strings.TrimSuffix(stuffs[1], entryExt)
-	return stuffs[0], entryPath
+	return stuffs[0], stuffs[1]
 }
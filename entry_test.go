@@ -341,6 +341,20 @@ func TestExtractEntryPath(t *testing.T) {
 			subdir:    "/path/to",
 			entryPath: "2012/12/18/post",
 		},
+		{
+			name:      "hatena syntax",
+			path:      "/path/to/entry/2012/12/18/post.hatena",
+			bc:        nil,
+			subdir:    "/path/to",
+			entryPath: "2012/12/18/post",
+		},
+		{
+			name:      "html",
+			path:      "/path/to/entry/about.html",
+			bc:        nil,
+			subdir:    "/path/to",
+			entryPath: "about",
+		},
 		{
 			name:      "invalid path",
 			path:      "/path/to/invalid/path.md",
@@ -374,4 +388,173 @@ func TestExtractEntryPath(t *testing.T) {
 			}
 		})
 	}
-}
\ No newline at end of file
+}
+
+func TestBlogID(t *testing.T) {
+	testCases := []struct {
+		name    string
+		editURL string
+		blogID  string
+		entryID string
+	}{
+		{
+			name:    "hatena",
+			editURL: "https://blog.hatena.ne.jp/Songmu/songmu.hateblog.jp/atom/entry/6801883189050452361",
+			blogID:  "songmu.hateblog.jp",
+			entryID: "6801883189050452361",
+		},
+		{
+			name:    "static page",
+			editURL: "https://blog.hatena.ne.jp/Songmu/songmu.hateblog.jp/atom/page/6801883189050452361",
+			blogID:  "songmu.hateblog.jp",
+			entryID: "6801883189050452361",
+		},
+		{
+			name:    "local endpoint",
+			editURL: "http://127.0.0.1:8080/Songmu/songmu.hateblog.jp/atom/entry/1",
+			blogID:  "songmu.hateblog.jp",
+			entryID: "1",
+		},
+		{
+			name:    "endpoint with path",
+			editURL: "http://localhost/hatena/api/Songmu/songmu.hateblog.jp/atom/entry/1",
+			blogID:  "songmu.hateblog.jp",
+			entryID: "1",
+		},
+		{
+			name:    "invalid",
+			editURL: "http://localhost/entry/1",
+			blogID:  "",
+			entryID: "",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			eh := &entryHeader{EditURL: tc.editURL}
+			blogID, err := eh.blogID()
+			if tc.blogID == "" {
+				if err == nil {
+					t.Errorf("error should be occurred but got blogID: %s", blogID)
+				}
+			} else if err != nil {
+				t.Errorf("error should be nil but: %s", err)
+			}
+			if blogID != tc.blogID {
+				t.Errorf("blogID: got %#v, want %#v", blogID, tc.blogID)
+			}
+			if entryID := eh.entryID(); entryID != tc.entryID {
+				t.Errorf("entryID: got %#v, want %#v", entryID, tc.entryID)
+			}
+		})
+	}
+}
+
+func TestEntryExt(t *testing.T) {
+	testCases := []struct {
+		contentType string
+		ext         string
+	}{
+		{contentType: "text/x-markdown", ext: ".md"},
+		{contentType: "text/x-hatena-syntax", ext: ".hatena"},
+		{contentType: "text/html", ext: ".html"},
+		{contentType: "text/plain", ext: ".txt"},
+		{contentType: "", ext: ".md"},
+		{contentType: "text/x-unknown", ext: ".md"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.contentType, func(t *testing.T) {
+			if g := extForContentType(tc.contentType); g != tc.ext {
+				t.Errorf("extForContentType: got %q, want %q", g, tc.ext)
+			}
+			if tc.contentType != "" && tc.contentType != "text/x-unknown" {
+				if g := contentTypeForPath("foo" + tc.ext); g != tc.contentType {
+					t.Errorf("contentTypeForPath: got %q, want %q", g, tc.contentType)
+				}
+			}
+		})
+	}
+	if g := contentTypeForPath("foo.png"); g != "" {
+		t.Errorf("contentTypeForPath should be empty for unknown extension but: %q", g)
+	}
+}
+
+func TestExtraHeaders(t *testing.T) {
+	testCases := []struct {
+		name   string
+		input  string
+		expect string
+	}{{
+		name: "interleaved keys",
+		input: `---
+Title: extra
+Series: blogsync
+EditURL: https://blog.hatena.ne.jp/motemen/motemen.hatenablog.com/atom/entry/1
+Reviewer:
+- alice
+- bob
+OGImage: https://example.com/og.png
+---
+
+body
+`,
+	}, {
+		name: "values kept as written",
+		input: `---
+Published: yes
+Code: 010
+Reviewed: 2020-01-02
+Note: "quoted"   # comment
+Title: values
+---
+
+body
+`,
+	}, {
+		name: "known keys in the canonical order",
+		input: `---
+Series: blogsync
+Draft: true
+Private: yes
+Title: order
+---
+
+body
+`,
+		expect: `---
+Series: blogsync
+Title: order
+Draft: true
+Private: yes
+---
+
+body
+`,
+	}}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			e, err := entryFromReader(strings.NewReader(tc.input))
+			if err != nil {
+				t.Fatal(err)
+			}
+			expect := tc.expect
+			if expect == "" {
+				expect = tc.input
+			}
+			if g := e.fullContent(); g != expect {
+				t.Errorf("unknown keys should be kept as written:\n got: %q\nwant: %q", g, expect)
+			}
+		})
+	}
+
+	e, err := entryFromReader(strings.NewReader(testCases[0].input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if g := e.ExtraMap()["Series"]; g != "blogsync" {
+		t.Errorf("ExtraMap: got %v", g)
+	}
+	if _, ok := e.ExtraMap()["Title"]; ok {
+		t.Errorf("ExtraMap should not have known keys")
+	}
+}
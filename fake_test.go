@@ -0,0 +1,316 @@
+package main
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/x-motemen/blogsync/atom"
+)
+
+func TestBlogsyncWithFakeServer(t *testing.T) {
+	s, dir := setupFakeBlog(t)
+	s.PerPage = 1
+
+	d1 := time.Date(2020, 1, 2, 3, 4, 5, 0, jst)
+	e1, err := s.AddEntry(&atom.Entry{
+		Title:   "entry1",
+		Content: atom.Content{Content: "entry1\n"},
+		Updated: &d1,
+		Edited:  &d1,
+	}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d2 := time.Date(2021, 2, 3, 4, 5, 6, 0, jst)
+	if _, err := s.AddEntry(&atom.Entry{
+		Title:     "entry2",
+		Content:   atom.Content{Content: "entry2\n"},
+		Updated:   &d2,
+		Edited:    &d2,
+		CustomURL: "custom/entry2",
+		Category:  []atom.Category{{Term: "foo"}},
+	}, false); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.AddEntry(&atom.Entry{
+		Title:     "about",
+		Content:   atom.Content{Content: "about\n"},
+		Updated:   &d2,
+		Edited:    &d2,
+		CustomURL: "about",
+	}, true); err != nil {
+		t.Fatal(err)
+	}
+
+	app := newApp()
+	blogsync := blogsyncApp(app)
+
+	entry1File := filepath.Join(dir, "entry", "2020", "01", "02", "030405.md")
+	t.Run("pull", func(t *testing.T) {
+		out, err := blogsync("pull")
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, f := range []string{
+			entry1File,
+			filepath.Join(dir, "entry", "custom", "entry2.md"),
+			filepath.Join(dir, "about.md"),
+		} {
+			if !exists(f) {
+				t.Errorf("%s should be pulled. output: %s", f, out)
+			}
+		}
+		e, err := entryFromFile(filepath.Join(dir, "entry", "custom", "entry2.md"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if e.Title != "entry2" || len(e.Category) != 1 || e.Category[0] != "foo" {
+			t.Errorf("unexpected entry: %+v", e.entryHeader)
+		}
+	})
+
+	t.Run("push", func(t *testing.T) {
+		if err := appendFile(entry1File, "updated\n"); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := blogsync("push", entry1File); err != nil {
+			t.Fatal(err)
+		}
+		re := s.Entry(e1.Links.Find("edit").Href)
+		if re.Content.Content != "entry1\nupdated\n" {
+			t.Errorf("unexpected remote content: %q", re.Content.Content)
+		}
+	})
+
+	t.Run("fetch", func(t *testing.T) {
+		editURL := e1.Links.Find("edit").Href
+		s.Now = func() time.Time { return time.Now().Add(time.Hour) }
+		defer func() { s.Now = nil }()
+		s.UpdateEntry(editURL, &atom.Entry{
+			Title:   "entry1 fixed",
+			Content: atom.Content{Content: "fixed on web\n"},
+		})
+		if _, err := blogsync("fetch", entry1File); err != nil {
+			t.Fatal(err)
+		}
+		e, err := entryFromFile(entry1File)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if e.Title != "entry1 fixed" || e.Content != "fixed on web\n" {
+			t.Errorf("remote change is not fetched: %+v", e)
+		}
+	})
+
+	t.Run("post draft, publish and remove", func(t *testing.T) {
+		app.Reader = strings.NewReader("draft\n")
+		entryFile, err := blogsync("post", "--draft", fakeBlogID)
+		app.Reader = os.Stdin
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !draftFileReg.MatchString(filepath.ToSlash(entryFile)) {
+			t.Fatalf("unexpected draft file: %s", entryFile)
+		}
+
+		publishedFile, err := blogsync("push", "--publish", entryFile)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if exists(entryFile) {
+			t.Errorf("draft file not deleted: %s", entryFile)
+		}
+		_, entryPath := (&blogConfig{}).extractEntryPath(publishedFile)
+		if !isLikelyGivenPath(entryPath) {
+			t.Errorf("unexpected published file: %s", publishedFile)
+		}
+
+		e, err := entryFromFile(publishedFile)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := blogsync("remove", "--yes", publishedFile); err != nil {
+			t.Fatal(err)
+		}
+		if exists(publishedFile) {
+			t.Errorf("removed file still exists: %s", publishedFile)
+		}
+		if s.Entry(e.EditURL) != nil {
+			t.Errorf("remote entry is not removed: %s", e.EditURL)
+		}
+	})
+
+	t.Run("post static page without custom path", func(t *testing.T) {
+		app.Reader = strings.NewReader("static\n")
+		_, err := blogsync("post", "--page", fakeBlogID)
+		app.Reader = os.Stdin
+		if err == nil {
+			t.Error("expected error did not occur")
+		}
+	})
+}
+
+func TestTimeout(t *testing.T) {
+	s, dir := setupFakeBlog(t)
+	h := s.Config.Handler
+	s.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(500 * time.Millisecond)
+		h.ServeHTTP(w, r)
+	})
+	if err := appendFile(filepath.Join(dir, "blogsync.yaml"), "  max_retries: 0\n"); err != nil {
+		t.Fatal(err)
+	}
+
+	blogsync := blogsyncApp(newApp())
+	defer func() { requestTimeout = defaultRequestTimeout }()
+	if _, err := blogsync("--timeout", "50ms", "pull"); err == nil || !strings.Contains(err.Error(), "Timeout") {
+		t.Errorf("request should time out but: %v", err)
+	}
+	if _, err := blogsync("--timeout", "0", "pull"); err != nil {
+		t.Errorf("error should be nil but: %s", err)
+	}
+}
+
+func TestAPIErrorMessages(t *testing.T) {
+	s, dir := setupFakeBlog(t)
+	editURL := addFakeEntry(t, s, "deleted", "deleted")
+
+	blogsync := blogsyncApp(newApp())
+	if _, err := blogsync("pull"); err != nil {
+		t.Fatal(err)
+	}
+	s.Delete(editURL)
+
+	entryFile := filepath.Join(dir, "entry", "deleted.md")
+	if _, err := blogsync("fetch", entryFile); err == nil || !strings.Contains(err.Error(), "not found on remote") {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	t.Log("Removing an entry already deleted on remote deletes the local file")
+	if _, err := blogsync("remove", "--yes", entryFile); err != nil {
+		t.Errorf("error should be nil but: %s", err)
+	}
+	if exists(entryFile) {
+		t.Errorf("%s should be removed", entryFile)
+	}
+
+	s.APIKey = "wrong"
+	if _, err := blogsync("pull", "--full"); err == nil || !strings.Contains(err.Error(), "authentication failed") {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestContentTypes(t *testing.T) {
+	s, dir := setupFakeBlog(t)
+	var editURLs []string
+	for _, ct := range []string{"text/x-hatena-syntax", "text/html", "text/x-markdown"} {
+		editURLs = append(editURLs, addFakeAtomEntry(t, s, &atom.Entry{
+			Title:     ct,
+			Content:   atom.Content{Type: ct, Content: "content\n"},
+			CustomURL: strings.ReplaceAll(ct, "/", "-"),
+		}))
+	}
+
+	blogsync := blogsyncApp(newApp())
+	if _, err := blogsync("pull"); err != nil {
+		t.Fatal(err)
+	}
+	hatenaFile := filepath.Join(dir, "entry", "text-x-hatena-syntax.hatena")
+	for _, f := range []string{
+		hatenaFile,
+		filepath.Join(dir, "entry", "text-html.html"),
+		filepath.Join(dir, "entry", "text-x-markdown.md"),
+	} {
+		if !exists(f) {
+			t.Errorf("%s should be pulled", f)
+		}
+	}
+	out, err := blogsync("status")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out, "nothing to sync") {
+		t.Errorf("all files should be recognized as entries: %s", out)
+	}
+
+	if err := appendFile(hatenaFile, "*[foo] hatena\n"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := blogsync("push", hatenaFile); err != nil {
+		t.Fatal(err)
+	}
+	if ct := s.Entry(editURLs[0]).Content.Type; ct != "text/x-hatena-syntax" {
+		t.Errorf("content type should be kept but: %s", ct)
+	}
+
+	t.Log("Syntax of an entry in a .md file pulled by older versions is not changed")
+	legacyFile := filepath.Join(dir, "entry", "legacy.md")
+	if err := os.Rename(hatenaFile, legacyFile); err != nil {
+		t.Fatal(err)
+	}
+	if err := appendFile(legacyFile, "legacy\n"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := blogsync("push", legacyFile); err != nil {
+		t.Fatal(err)
+	}
+	if ct := s.Entry(editURLs[0]).Content.Type; ct != "text/x-hatena-syntax" {
+		t.Errorf("content type should be kept but: %s", ct)
+	}
+}
+
+func TestExtraHeadersRoundTrip(t *testing.T) {
+	s, dir := setupFakeBlog(t)
+	editURL := addFakeEntry(t, s, "extra", "extra")
+
+	blogsync := blogsyncApp(newApp())
+	if _, err := blogsync("pull"); err != nil {
+		t.Fatal(err)
+	}
+	entryFile := filepath.Join(dir, "entry", "extra.md")
+	bb, err := os.ReadFile(entryFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	content := strings.Replace(string(bb), "Title: extra\n", "Title: extra\nSeries: blogsync\n", 1)
+	content = strings.Replace(content, "---\n\n", "Reviewer: alice\nPublished: yes\nCode: 010\n---\n\n", 1)
+	if err := os.WriteFile(entryFile, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	hasExtra := func(t *testing.T) {
+		t.Helper()
+		bb, err := os.ReadFile(entryFile)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(string(bb), "Title: extra\nSeries: blogsync\n") ||
+			!strings.Contains(string(bb), "Reviewer: alice\nPublished: yes\nCode: 010\n---\n") {
+			t.Errorf("extra keys should be kept: %s", string(bb))
+		}
+	}
+
+	t.Log("Extra keys survive push")
+	if _, err := blogsync("push", entryFile); err != nil {
+		t.Fatal(err)
+	}
+	hasExtra(t)
+
+	t.Log("Extra keys survive pull of the remote change")
+	s.Now = func() time.Time { return time.Now().Add(time.Hour) }
+	defer func() { s.Now = nil }()
+	s.UpdateEntry(editURL, &atom.Entry{
+		Title:   "extra",
+		Content: atom.Content{Content: "extra updated\n"},
+	})
+	if _, err := blogsync("pull"); err != nil {
+		t.Fatal(err)
+	}
+	hasExtra(t)
+	if bb, _ := os.ReadFile(entryFile); !strings.Contains(string(bb), "extra updated") {
+		t.Errorf("remote change should be pulled: %s", string(bb))
+	}
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"os"
+	"strings"
+)
+
+// formattedHTMLSuffix is the suffix of the file in which the HTML rendered by
+// Hatena Blog is stored next to the entry file by pull --with-html.
+const formattedHTMLSuffix = ".formatted.html"
+
+// formattedHTMLPath returns the path of the rendered HTML for the entry file at
+// path, such as "entry/foo.formatted.html" for "entry/foo.md".
+func formattedHTMLPath(path string) string {
+	return trimEntryExt(path) + formattedHTMLSuffix
+}
+
+func isFormattedHTML(path string) bool {
+	return strings.HasSuffix(path, formattedHTMLSuffix)
+}
+
+// storeFormattedHTML writes the rendered HTML of e next to the entry file at
+// path. The file is not touched if the content is not changed.
+func (b *broker) storeFormattedHTML(e *entry, path string) error {
+	if e.FormattedContent == "" {
+		return nil
+	}
+	htmlPath := formattedHTMLPath(path)
+	if bb, err := os.ReadFile(htmlPath); err == nil && string(bb) == e.FormattedContent {
+		return nil
+	}
+	if !dryRun {
+		b.logf("store", "%s", htmlPath)
+	}
+	return b.writeFile(htmlPath, []byte(e.FormattedContent))
+}
+
+// removeFormattedHTML removes the rendered HTML for the entry file at path if any.
+func (b *broker) removeFormattedHTML(path string) error {
+	return b.removeFile(formattedHTMLPath(path))
+}
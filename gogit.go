@@ -0,0 +1,108 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"path/filepath"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+var errStopWalk = errors.New("stop walking commits")
+
+// loadGoGitTimes takes the snapshot of the repository at root in process with
+// go-git, without the git command. The shallow repository is read as is.
+func loadGoGitTimes(root string) (*gitTimes, error) {
+	repo, err := git.PlainOpen(root)
+	if err != nil {
+		return nil, err
+	}
+	head, err := repo.Head()
+	if err != nil {
+		return nil, err
+	}
+	headCommit, err := repo.CommitObject(head.Hash())
+	if err != nil {
+		return nil, err
+	}
+	headTree, err := headCommit.Tree()
+	if err != nil {
+		return nil, err
+	}
+	// The walk stops when the last commits of all files in HEAD are found
+	rest := map[string]bool{}
+	if err := headTree.Files().ForEach(func(f *object.File) error {
+		rest[f.Name] = true
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	gt := &gitTimes{
+		authorDates: map[string]time.Time{},
+		modified:    map[string]bool{},
+	}
+	iter, err := repo.Log(&git.LogOptions{From: head.Hash(), Order: git.LogOrderCommitterTime})
+	if err != nil {
+		return nil, err
+	}
+	err = iter.ForEach(func(c *object.Commit) error {
+		// Merge commits are skipped as "git log --name-only" does
+		if c.NumParents() > 1 {
+			return nil
+		}
+		tree, err := c.Tree()
+		if err != nil {
+			return err
+		}
+		var parentTree *object.Tree
+		if c.NumParents() == 1 {
+			parent, err := c.Parent(0)
+			if err != nil {
+				// The parent is missing in a shallow repository
+				parentTree = nil
+			} else if parentTree, err = parent.Tree(); err != nil {
+				return err
+			}
+		}
+		changes, err := object.DiffTree(parentTree, tree)
+		if err != nil {
+			return err
+		}
+		for _, ch := range changes {
+			name := ch.To.Name
+			if name == "" {
+				continue // deleted
+			}
+			p := filepath.Join(root, filepath.FromSlash(name))
+			if _, ok := gt.authorDates[p]; !ok {
+				gt.authorDates[p] = c.Author.When
+				delete(rest, name)
+			}
+		}
+		if len(rest) == 0 {
+			return errStopWalk
+		}
+		return nil
+	})
+	if err != nil && err != errStopWalk && err != io.EOF {
+		return nil, err
+	}
+
+	wt, err := repo.Worktree()
+	if err != nil {
+		return nil, err
+	}
+	status, err := wt.Status()
+	if err != nil {
+		return nil, err
+	}
+	for name, st := range status {
+		if st.Staging != git.Unmodified || st.Worktree != git.Unmodified {
+			gt.modified[filepath.Join(root, filepath.FromSlash(name))] = true
+		}
+	}
+	return gt, nil
+}
@@ -0,0 +1,72 @@
+package hatenatest
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/x-motemen/blogsync/atom"
+)
+
+const fotolifePath = "/atom/post"
+
+// FotolifeEndpoint returns the URL to post images to as Hatena Fotolife.
+func (s *Server) FotolifeEndpoint() string {
+	return s.URL + fotolifePath
+}
+
+// Images returns the copies of the posted images in the posted order.
+func (s *Server) Images() []*atom.Image {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	images := make([]*atom.Image, len(s.images))
+	for i, img := range s.images {
+		ci := *img
+		ci.Links = append(atom.Links{}, img.Links...)
+		images[i] = &ci
+	}
+	return images
+}
+
+// fotolifeTypes maps the content types of images to the type characters in the
+// Hatena syntax and the extensions of image URLs.
+var fotolifeTypes = map[string]struct{ char, ext string }{
+	"image/png":  {"p", "png"},
+	"image/jpeg": {"j", "jpg"},
+	"image/gif":  {"g", "gif"},
+}
+
+func (s *Server) serveFotolife(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "405 Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	img, err := atom.ParseImage(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if img.Content.Mode != "base64" || !strings.HasPrefix(img.Content.Type, "image/") {
+		http.Error(w, "invalid content", http.StatusBadRequest)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Fotolife names images by the uploaded time in seconds
+	ts := s.now().Add(time.Duration(len(s.images)) * time.Second)
+	id := ts.Format("20060102150405")
+	ft, ok := fotolifeTypes[img.Content.Type]
+	if !ok {
+		ft.ext = strings.TrimPrefix(img.Content.Type, "image/")
+	}
+	img.ID = fmt.Sprintf("tag:hatena.ne.jp,2005:fotolife-%s-%s", s.Username, id)
+	img.Links = atom.Links{{Rel: "alternate", Href: fmt.Sprintf("https://f.hatena.ne.jp/%s/%s", s.Username, id)}}
+	img.Syntax = fmt.Sprintf("f:id:%s:%s%s:image", s.Username, id, ft.char)
+	img.ImageURL = fmt.Sprintf("https://cdn-ak.f.st-hatena.com/images/fotolife/%s/%s/%s/%s.%s",
+		s.Username[:1], s.Username, id[:8], id, ft.ext)
+	s.images = append(s.images, img)
+	writeXML(w, http.StatusCreated, img)
+}
@@ -0,0 +1,150 @@
+package hatenatest
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+const (
+	oauthInitiatePath = "/oauth/initiate"
+	oauthTokenPath    = "/oauth/token"
+)
+
+// OAuthVerifier is the verification code which the server accepts for any
+// request token, as if the user allowed the access in the browser.
+const OAuthVerifier = "blogsynctest-verifier"
+
+// OAuthEndpoint returns the base URL of the OAuth endpoints, under which the
+// temporary credentials are issued at /initiate and the access tokens at /token.
+func (s *Server) OAuthEndpoint() string {
+	return s.URL + "/oauth/"
+}
+
+// AddAccessToken adds the access token of OAuth which the server accepts.
+func (s *Server) AddAccessToken(token, secret string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.accessTokens[token] = secret
+}
+
+func newToken() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+func oauthEscape(s string) string {
+	return strings.ReplaceAll(url.QueryEscape(s), "+", "%20")
+}
+
+var oauthParamReg = regexp.MustCompile(`(\w+)="([^"]*)"`)
+
+// verifyOAuth verifies the OAuth signature of r with the token secret looked up
+// in tokens, and returns the protocol parameters. No token is expected if tokens
+// is nil.
+func (s *Server) verifyOAuth(r *http.Request, tokens map[string]string) (map[string]string, bool) {
+	h := r.Header.Get("Authorization")
+	if !strings.HasPrefix(h, "OAuth ") || s.ConsumerKey == "" {
+		return nil, false
+	}
+	params := map[string]string{}
+	for _, m := range oauthParamReg.FindAllStringSubmatch(h, -1) {
+		v, err := url.QueryUnescape(m[2])
+		if err != nil {
+			return nil, false
+		}
+		params[m[1]] = v
+	}
+	if params["oauth_consumer_key"] != s.ConsumerKey || params["oauth_signature_method"] != "HMAC-SHA1" {
+		return nil, false
+	}
+	token, hasToken := params["oauth_token"]
+	if hasToken != (tokens != nil) {
+		return nil, false
+	}
+	tokenSecret, ok := tokens[token]
+	if hasToken && !ok {
+		return nil, false
+	}
+
+	var pairs []string
+	for k, v := range params {
+		if k != "oauth_signature" {
+			pairs = append(pairs, oauthEscape(k)+"="+oauthEscape(v))
+		}
+	}
+	values := r.URL.Query()
+	if r.Header.Get("Content-Type") == "application/x-www-form-urlencoded" {
+		if err := r.ParseForm(); err != nil {
+			return nil, false
+		}
+		values = r.Form
+	}
+	for k, vs := range values {
+		for _, v := range vs {
+			pairs = append(pairs, oauthEscape(k)+"="+oauthEscape(v))
+		}
+	}
+	sort.Strings(pairs)
+	base := r.Method + "&" + oauthEscape("http://"+r.Host+r.URL.EscapedPath()) + "&" + oauthEscape(strings.Join(pairs, "&"))
+	mac := hmac.New(sha1.New, []byte(oauthEscape(s.ConsumerSecret)+"&"+oauthEscape(tokenSecret)))
+	mac.Write([]byte(base))
+	if !hmac.Equal([]byte(base64.StdEncoding.EncodeToString(mac.Sum(nil))), []byte(params["oauth_signature"])) {
+		return nil, false
+	}
+	return params, true
+}
+
+func writeForm(w http.ResponseWriter, v url.Values) {
+	w.Header().Set("Content-Type", "application/x-www-form-urlencoded")
+	fmt.Fprint(w, v.Encode())
+}
+
+func (s *Server) serveOAuth(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "405 Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	switch r.URL.Path {
+	case oauthInitiatePath:
+		params, ok := s.verifyOAuth(r, nil)
+		if !ok || params["oauth_callback"] == "" || r.Form.Get("scope") == "" {
+			http.Error(w, "401 Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		token, secret := newToken(), newToken()
+		s.requestTokens[token] = secret
+		writeForm(w, url.Values{
+			"oauth_token":              {token},
+			"oauth_token_secret":       {secret},
+			"oauth_callback_confirmed": {"true"},
+		})
+	case oauthTokenPath:
+		params, ok := s.verifyOAuth(r, s.requestTokens)
+		if !ok || params["oauth_verifier"] != OAuthVerifier {
+			http.Error(w, "401 Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		delete(s.requestTokens, params["oauth_token"])
+		token, secret := newToken(), newToken()
+		s.accessTokens[token] = secret
+		writeForm(w, url.Values{
+			"oauth_token":        {token},
+			"oauth_token_secret": {secret},
+			"url_name":           {s.Username},
+		})
+	default:
+		http.NotFound(w, r)
+	}
+}
@@ -0,0 +1,526 @@
+// Package hatenatest provides an in-process fake of the Hatena Blog AtomPub API
+// for testing blogsync without a real blog.
+package hatenatest
+
+import (
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/xml"
+	"fmt"
+	"html"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/x-motemen/blogsync/atom"
+)
+
+// Server is a fake Hatena Blog AtomPub server backed by httptest.Server.
+//
+// It serves the entry collection at /{owner}/{blogID}/atom/entry and the static
+// page collection at /{owner}/{blogID}/atom/page, so that Endpoint can be used as
+// the endpoint of blogsync configuration. Images are posted to /atom/post as the
+// Hatena Fotolife API.
+//
+// Requests are authenticated by WSSE or Basic authentication with Username and
+// APIKey, or by OAuth 1.0a when ConsumerKey and ConsumerSecret are set.
+type Server struct {
+	*httptest.Server
+
+	BlogID   string
+	Username string
+	APIKey   string
+	// Owner is the owner of the blog. Username is used when it is empty.
+	Owner string
+	// PerPage is the number of entries in a page of the collection. Defaults to 10.
+	PerPage int
+	// Now returns the current time used for app:edited and so on. Defaults to time.Now.
+	Now func() time.Time
+	// ConsumerKey and ConsumerSecret are of the OAuth consumer which the server accepts
+	ConsumerKey    string
+	ConsumerSecret string
+
+	mu      sync.Mutex
+	entries []*atom.Entry
+	pages   []*atom.Entry
+	images  []*atom.Image
+	nextID  int64
+
+	// requestTokens and accessTokens are the secrets of the OAuth tokens
+	requestTokens map[string]string
+	accessTokens  map[string]string
+}
+
+// NewServer starts and returns a new Server. The caller should call Close when
+// finished, to shut it down.
+func NewServer(blogID, username, apiKey string) *Server {
+	s := &Server{
+		BlogID:   blogID,
+		Username: username,
+		APIKey:   apiKey,
+		nextID:   6801883189050452361,
+
+		requestTokens: map[string]string{},
+		accessTokens:  map[string]string{},
+	}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+	return s
+}
+
+// Endpoint returns the base URL of the AtomPub API with a trailing slash.
+func (s *Server) Endpoint() string {
+	return s.URL + "/"
+}
+
+// RootURL returns the URL of the blog top page.
+func (s *Server) RootURL() string {
+	return "https://" + s.BlogID + "/"
+}
+
+func (s *Server) owner() string {
+	if s.Owner != "" {
+		return s.Owner
+	}
+	return s.Username
+}
+
+func (s *Server) now() time.Time {
+	if s.Now != nil {
+		return s.Now().Truncate(time.Second)
+	}
+	return time.Now().Truncate(time.Second)
+}
+
+func (s *Server) perPage() int {
+	if s.PerPage > 0 {
+		return s.PerPage
+	}
+	return 10
+}
+
+func (s *Server) collectionURL(isPage bool) string {
+	kind := "entry"
+	if isPage {
+		kind = "page"
+	}
+	return fmt.Sprintf("%s/%s/%s/atom/%s", s.URL, s.owner(), s.BlogID, kind)
+}
+
+// AddEntry stores a copy of e as if it was posted, without going through HTTP.
+// The fields left empty, such as ID, links and app:edited, are filled in the same
+// way as POST. It returns the stored entry.
+func (s *Server) AddEntry(e *atom.Entry, isPage bool) (*atom.Entry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.add(e, isPage)
+}
+
+// Entry returns a copy of the stored entry or static page for editURL, or nil if
+// not found.
+func (s *Server) Entry(editURL string) *atom.Entry {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, i, list := s.find(editURL)
+	if i < 0 {
+		return nil
+	}
+	return copyEntry(list[i])
+}
+
+// Entries returns copies of the stored blog entries ordered by app:edited descending.
+func (s *Server) Entries() []*atom.Entry {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return copyEntries(s.entries)
+}
+
+// Pages returns copies of the stored static pages ordered by app:edited descending.
+func (s *Server) Pages() []*atom.Entry {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return copyEntries(s.pages)
+}
+
+// UpdateEntry updates the entry for editURL as if it was edited on the web UI.
+// It returns nil if the entry is not found.
+func (s *Server) UpdateEntry(editURL string, e *atom.Entry) *atom.Entry {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ne, _ := s.update(editURL, e)
+	return ne
+}
+
+// Delete removes the entry for editURL as if it was deleted on the web UI.
+// It reports whether the entry existed.
+func (s *Server) Delete(editURL string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	isPage, i, _ := s.find(editURL)
+	if i < 0 {
+		return false
+	}
+	s.remove(isPage, i)
+	return true
+}
+
+func copyEntry(e *atom.Entry) *atom.Entry {
+	ce := *e
+	ce.Links = append(atom.Links{}, e.Links...)
+	ce.Category = append([]atom.Category{}, e.Category...)
+	if e.Control != nil {
+		c := *e.Control
+		ce.Control = &c
+	}
+	if e.FormattedContent != nil {
+		fc := *e.FormattedContent
+		ce.FormattedContent = &fc
+	}
+	return &ce
+}
+
+// formattedContent renders the content of e into HTML in a simplified way,
+// which only escapes it and wraps each paragraph with <p>.
+func formattedContent(e *atom.Entry) *atom.Content {
+	var buf strings.Builder
+	for _, para := range strings.Split(strings.TrimSpace(e.Content.Content), "\n\n") {
+		if para == "" {
+			continue
+		}
+		buf.WriteString("<p>" + html.EscapeString(para) + "</p>\n")
+	}
+	return &atom.Content{Type: "text/html", Content: buf.String()}
+}
+
+func copyEntries(entries []*atom.Entry) []*atom.Entry {
+	ret := make([]*atom.Entry, 0, len(entries))
+	for _, e := range entries {
+		ret = append(ret, copyEntry(e))
+	}
+	return ret
+}
+
+func isDraft(e *atom.Entry) bool {
+	return e.Control != nil && e.Control.Draft == "yes"
+}
+
+// find looks up the entry for editURL. It returns -1 as the index if not found.
+func (s *Server) find(editURL string) (isPage bool, idx int, list []*atom.Entry) {
+	for _, isPage := range []bool{false, true} {
+		list := s.entries
+		if isPage {
+			list = s.pages
+		}
+		for i, e := range list {
+			if l := e.Links.Find("edit"); l != nil && l.Href == editURL {
+				return isPage, i, list
+			}
+		}
+	}
+	return false, -1, nil
+}
+
+func (s *Server) remove(isPage bool, i int) {
+	if isPage {
+		s.pages = append(s.pages[:i], s.pages[i+1:]...)
+	} else {
+		s.entries = append(s.entries[:i], s.entries[i+1:]...)
+	}
+}
+
+func (s *Server) sort() {
+	for _, list := range [][]*atom.Entry{s.entries, s.pages} {
+		sort.SliceStable(list, func(i, j int) bool {
+			return list[i].Edited.After(*list[j].Edited)
+		})
+	}
+}
+
+var errCustomURLRequired = fmt.Errorf("custom-url is required for static pages")
+
+func (s *Server) add(e *atom.Entry, isPage bool) (*atom.Entry, error) {
+	if isPage && e.CustomURL == "" {
+		return nil, errCustomURLRequired
+	}
+	ne := copyEntry(e)
+	if ne.ID == "" {
+		s.nextID++
+		ne.ID = fmt.Sprintf("tag:blog.hatena.ne.jp,2013:blog-%s-%d", s.owner(), s.nextID)
+	}
+	if ne.Links.Find("edit") == nil {
+		id := ne.ID[strings.LastIndex(ne.ID, "-")+1:]
+		ne.Links = append(ne.Links, atom.Link{Rel: "edit", Href: s.collectionURL(isPage) + "/" + id})
+	}
+	now := s.now()
+	if ne.Edited == nil {
+		ne.Edited = &now
+	}
+	if ne.Updated == nil {
+		ne.Updated = &now
+	}
+	if ne.Published == nil {
+		ne.Published = ne.Updated
+	}
+	if ne.Author.Name == "" {
+		ne.Author.Name = s.Username
+	}
+	if ne.Content.Type == "" {
+		ne.Content.Type = "text/x-markdown"
+	}
+	ne.FormattedContent = formattedContent(ne)
+	if ne.Control == nil {
+		ne.Control = &atom.Control{Draft: "no", Preview: "no"}
+	}
+	s.setAlternate(ne, isPage, "")
+	if isPage {
+		s.pages = append(s.pages, ne)
+	} else {
+		s.entries = append(s.entries, ne)
+	}
+	s.sort()
+	return copyEntry(ne), nil
+}
+
+// setAlternate assigns the alternate and preview links as Hatena Blog does.
+// A published entry without custom-url keeps the path once assigned, but a
+// draft without custom-url gets a temporary path on every request.
+func (s *Server) setAlternate(e *atom.Entry, isPage bool, oldPath string) {
+	var p string
+	switch {
+	case e.CustomURL != "" && isPage:
+		p = e.CustomURL
+	case e.CustomURL != "":
+		p = "entry/" + e.CustomURL
+	case isDraft(e):
+		p = "entry/" + s.now().Format("2006/01/02/150405")
+	case oldPath != "":
+		p = oldPath
+	default:
+		p = "entry/" + e.Updated.Format("2006/01/02/150405")
+	}
+	links := atom.Links{}
+	for _, l := range e.Links {
+		if l.Rel != "alternate" && l.Rel != "preview" {
+			links = append(links, l)
+		}
+	}
+	links = append(links, atom.Link{Rel: "alternate", Href: s.RootURL() + p})
+	if isDraft(e) {
+		edit := links.Find("edit").Href
+		links = append(links, atom.Link{Rel: "preview", Href: s.RootURL() + "draft/" + edit[strings.LastIndex(edit, "/")+1:]})
+	}
+	e.Links = links
+}
+
+func (s *Server) update(editURL string, e *atom.Entry) (*atom.Entry, bool) {
+	isPage, i, list := s.find(editURL)
+	if i < 0 {
+		return nil, false
+	}
+	old := list[i]
+	var oldPath string
+	if !isDraft(old) {
+		if l := old.Links.Find("alternate"); l != nil {
+			oldPath = strings.TrimPrefix(l.Href, s.RootURL())
+		}
+	}
+	ne := copyEntry(old)
+	ne.Title = e.Title
+	ne.Content = e.Content
+	if ne.Content.Type == "" {
+		ne.Content.Type = old.Content.Type
+	}
+	ne.FormattedContent = formattedContent(ne)
+	ne.Category = append([]atom.Category{}, e.Category...)
+	if e.Updated != nil {
+		ne.Updated = e.Updated
+	}
+	if e.CustomURL != "" {
+		ne.CustomURL = e.CustomURL
+	}
+	if e.Control != nil {
+		c := *e.Control
+		ne.Control = &c
+	} else {
+		ne.Control = &atom.Control{Draft: "no", Preview: "no"}
+	}
+	if isDraft(old) && !isDraft(ne) && e.Updated == nil {
+		now := s.now()
+		ne.Updated = &now
+	}
+	now := s.now()
+	ne.Edited = &now
+	s.setAlternate(ne, isPage, oldPath)
+	list[i] = ne
+	s.sort()
+	return copyEntry(ne), true
+}
+
+var wsseReg = regexp.MustCompile(`(\w+)="([^"]*)"`)
+
+func (s *Server) authorized(r *http.Request) bool {
+	if user, password, ok := r.BasicAuth(); ok {
+		return user == s.Username && password == s.APIKey
+	}
+	if strings.HasPrefix(r.Header.Get("Authorization"), "OAuth ") {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		_, ok := s.verifyOAuth(r, s.accessTokens)
+		return ok
+	}
+	h := r.Header.Get("X-WSSE")
+	if !strings.HasPrefix(h, "UsernameToken ") {
+		return false
+	}
+	params := map[string]string{}
+	for _, m := range wsseReg.FindAllStringSubmatch(h, -1) {
+		params[m[1]] = m[2]
+	}
+	if params["Username"] != s.Username {
+		return false
+	}
+	nonce, err := base64.StdEncoding.DecodeString(params["Nonce"])
+	if err != nil {
+		return false
+	}
+	digest := sha1.New()
+	digest.Write(nonce)
+	digest.Write([]byte(params["Created"]))
+	digest.Write([]byte(s.APIKey))
+	return base64.StdEncoding.EncodeToString(digest.Sum(nil)) == params["PasswordDigest"]
+}
+
+type feed struct {
+	XMLName xml.Name      `xml:"http://www.w3.org/2005/Atom feed"`
+	Links   atom.Links    `xml:"link"`
+	Title   string        `xml:"title"`
+	Entries []*atom.Entry `xml:"entry"`
+}
+
+func writeXML(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/atom+xml; charset=utf-8")
+	w.WriteHeader(status)
+	w.Write([]byte(xml.Header))
+	xml.NewEncoder(w).Encode(v)
+}
+
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	if strings.HasPrefix(r.URL.Path, "/oauth/") {
+		s.serveOAuth(w, r)
+		return
+	}
+	if !s.authorized(r) {
+		http.Error(w, "401 Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if r.URL.Path == fotolifePath {
+		s.serveFotolife(w, r)
+		return
+	}
+	prefix := fmt.Sprintf("/%s/%s/atom/", s.owner(), s.BlogID)
+	if !strings.HasPrefix(r.URL.Path, prefix) {
+		http.NotFound(w, r)
+		return
+	}
+	paths := strings.Split(strings.TrimPrefix(r.URL.Path, prefix), "/")
+	if paths[0] != "entry" && paths[0] != "page" {
+		http.NotFound(w, r)
+		return
+	}
+	isPage := paths[0] == "page"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	switch {
+	case len(paths) == 1 && r.Method == http.MethodGet:
+		s.serveFeed(w, r, isPage)
+	case len(paths) == 1 && r.Method == http.MethodPost:
+		e, err := atom.ParseEntry(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		ne, err := s.add(&atom.Entry{
+			Title:     e.Title,
+			Content:   e.Content,
+			Category:  e.Category,
+			Updated:   e.Updated,
+			Control:   e.Control,
+			CustomURL: e.CustomURL,
+		}, isPage)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		writeXML(w, http.StatusCreated, ne)
+	case len(paths) == 2:
+		editURL := s.collectionURL(isPage) + "/" + paths[1]
+		s.serveEntry(w, r, editURL)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (s *Server) serveFeed(w http.ResponseWriter, r *http.Request, isPage bool) {
+	list := s.entries
+	if isPage {
+		list = s.pages
+	}
+	offset := 0
+	if p := r.URL.Query().Get("page"); p != "" {
+		var err error
+		offset, err = strconv.Atoi(p)
+		if err != nil || offset < 0 {
+			http.Error(w, "invalid page", http.StatusBadRequest)
+			return
+		}
+	}
+	end := min(offset+s.perPage(), len(list))
+	offset = min(offset, end)
+
+	f := &feed{
+		Title: s.BlogID,
+		Links: atom.Links{
+			{Rel: "first", Href: s.collectionURL(isPage)},
+			{Rel: "alternate", Href: s.RootURL()},
+		},
+		Entries: list[offset:end],
+	}
+	if end < len(list) {
+		f.Links = append(f.Links, atom.Link{
+			Rel:  "next",
+			Href: fmt.Sprintf("%s?page=%d", s.collectionURL(isPage), end),
+		})
+	}
+	writeXML(w, http.StatusOK, f)
+}
+
+func (s *Server) serveEntry(w http.ResponseWriter, r *http.Request, editURL string) {
+	isPage, i, list := s.find(editURL)
+	if i < 0 {
+		http.NotFound(w, r)
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		writeXML(w, http.StatusOK, list[i])
+	case http.MethodPut:
+		e, err := atom.ParseEntry(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		ne, _ := s.update(editURL, e)
+		writeXML(w, http.StatusOK, ne)
+	case http.MethodDelete:
+		s.remove(isPage, i)
+		w.WriteHeader(http.StatusOK)
+	default:
+		http.Error(w, "405 Method Not Allowed", http.StatusMethodNotAllowed)
+	}
+}
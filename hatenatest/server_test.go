@@ -0,0 +1,153 @@
+package hatenatest
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/motemen/go-wsse"
+	"github.com/x-motemen/blogsync/atom"
+)
+
+func newClient(username, apiKey string) *atom.Client {
+	return &atom.Client{
+		Client: &http.Client{
+			Transport: &wsse.Transport{
+				Username: username,
+				Password: apiKey,
+			},
+		},
+	}
+}
+
+func TestServer(t *testing.T) {
+	s := NewServer("example.hatenablog.com", "sample", "apikey")
+	defer s.Close()
+	s.PerPage = 2
+
+	c := newClient("sample", "apikey")
+	entryURL := s.Endpoint() + "sample/example.hatenablog.com/atom/entry"
+
+	t.Run("unauthorized", func(t *testing.T) {
+		_, err := newClient("sample", "wrong").GetFeed(entryURL)
+		if err == nil || !strings.Contains(err.Error(), "401") {
+			t.Errorf("401 error should be occurred but: %v", err)
+		}
+	})
+
+	t.Run("basic auth", func(t *testing.T) {
+		for _, tc := range []struct {
+			apiKey string
+			status int
+		}{
+			{"apikey", http.StatusOK},
+			{"wrong", http.StatusUnauthorized},
+		} {
+			req, err := http.NewRequest("GET", entryURL, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.SetBasicAuth("sample", tc.apiKey)
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != tc.status {
+				t.Errorf("got %d with %q, want %d", resp.StatusCode, tc.apiKey, tc.status)
+			}
+		}
+	})
+
+	t.Run("post and paging", func(t *testing.T) {
+		for i := 0; i < 5; i++ {
+			_, err := c.PostEntry(entryURL, &atom.Entry{
+				Title:     fmt.Sprintf("entry%d", i),
+				Content:   atom.Content{Content: "body"},
+				CustomURL: fmt.Sprintf("entry%d", i),
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+		var count, pages int
+		for u := entryURL; u != ""; {
+			feed, err := c.GetFeed(u)
+			if err != nil {
+				t.Fatal(err)
+			}
+			pages++
+			count += len(feed.Entries)
+			u = ""
+			if l := feed.Links.Find("next"); l != nil {
+				u = l.Href
+			}
+		}
+		if count != 5 || pages != 3 {
+			t.Errorf("got %d entries in %d pages, want 5 entries in 3 pages", count, pages)
+		}
+	})
+
+	t.Run("draft and publish", func(t *testing.T) {
+		e, err := c.PostEntry(entryURL, &atom.Entry{
+			Title:   "draft",
+			Content: atom.Content{Content: "draft"},
+			Control: &atom.Control{Draft: "yes", Preview: "yes"},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if e.Links.Find("preview") == nil {
+			t.Errorf("draft should have preview link")
+		}
+		editURL := e.Links.Find("edit").Href
+		e.Control = nil
+		pe, err := c.PutEntry(editURL, e)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if pe.Control.Draft != "no" {
+			t.Errorf("entry should be published")
+		}
+		if !pe.Edited.After(*e.Edited) && !pe.Edited.Equal(*e.Edited) {
+			t.Errorf("app:edited should be updated")
+		}
+		if err := c.DeleteEntry(editURL); err != nil {
+			t.Fatal(err)
+		}
+		if s.Entry(editURL) != nil {
+			t.Errorf("entry should be deleted")
+		}
+	})
+
+	t.Run("static page requires custom-url", func(t *testing.T) {
+		pageURL := s.Endpoint() + "sample/example.hatenablog.com/atom/page"
+		if _, err := c.PostEntry(pageURL, &atom.Entry{Title: "page"}); err == nil {
+			t.Errorf("error should be occurred")
+		}
+		if _, err := c.PostEntry(pageURL, &atom.Entry{Title: "page", CustomURL: "about"}); err != nil {
+			t.Error(err)
+		}
+		if g := len(s.Pages()); g != 1 {
+			t.Errorf("got %d pages, want 1", g)
+		}
+	})
+
+	t.Run("fotolife", func(t *testing.T) {
+		img, err := c.PostImage(s.FotolifeEndpoint(), atom.NewImage("foo.png", "image/png", []byte("\x89PNG")))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.HasPrefix(img.Syntax, "f:id:sample:") || !strings.HasSuffix(img.Syntax, "p:image") {
+			t.Errorf("unexpected syntax: %s", img.Syntax)
+		}
+		if !strings.HasPrefix(img.ImageURL, "https://cdn-ak.f.st-hatena.com/images/fotolife/s/sample/") {
+			t.Errorf("unexpected image URL: %s", img.ImageURL)
+		}
+		images := s.Images()
+		if len(images) != 1 || images[0].Title != "foo.png" || images[0].Content.Content != "iVBORw==" {
+			t.Errorf("unexpected images: %+v", images)
+		}
+	})
+}
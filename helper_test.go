@@ -0,0 +1,117 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/urfave/cli/v2"
+	"github.com/x-motemen/blogsync/atom"
+	"github.com/x-motemen/blogsync/hatenatest"
+)
+
+func blogsyncApp(app *cli.App) func(...string) (string, error) {
+	buf := &bytes.Buffer{}
+	app.Writer = buf
+	return func(args ...string) (string, error) {
+		buf.Reset()
+		err := app.Run(append([]string{""}, args...))
+		return strings.TrimSpace(buf.String()), err
+	}
+}
+
+func exists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+var draftFileReg = regexp.MustCompile(`entry/_draft/\d+\.md$`)
+
+func appendFile(path string, content string) error {
+	fh, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := fh.WriteString(content); err != nil {
+		return err
+	}
+	return fh.Close()
+}
+
+const (
+	fakeBlogID   = "blogsynctest.hatenablog.com"
+	fakeUsername = "blogsynctest"
+	fakeAPIKey   = "apikey"
+)
+
+// setupFakeBlog starts a fake AtomPub server and prepares a working directory
+// with blogsync.yaml pointing to it. The working directory is returned.
+func setupFakeBlog(t *testing.T) (*hatenatest.Server, string) {
+	t.Helper()
+
+	s := hatenatest.NewServer(fakeBlogID, fakeUsername, fakeAPIKey)
+	t.Cleanup(s.Close)
+
+	pwd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("BLOGSYNC_WORKDIR", dir)
+	t.Setenv(homeEnvName, dir)
+	t.Setenv("BLOGSYNC_USERNAME", "")
+	t.Setenv("BLOGSYNC_PASSWORD", "")
+	t.Setenv("BLOGSYNC_ENDPOINT", "")
+	t.Cleanup(func() {
+		if err := os.Chdir(pwd); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	confYAML := fmt.Sprintf(`%s:
+  local_root: .
+  omit_domain: true
+  username: %s
+  password: %s
+  endpoint: %s
+`, fakeBlogID, fakeUsername, fakeAPIKey, s.Endpoint())
+	if err := os.WriteFile(filepath.Join(dir, "blogsync.yaml"), []byte(confYAML), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return s, dir
+}
+
+// fakeEntryDate is the date of the entries added by addFakeEntry.
+var fakeEntryDate = time.Date(2020, 1, 2, 3, 4, 5, 0, time.FixedZone("JST", 9*60*60))
+
+// addFakeEntry adds a published entry whose content is the title to the fake
+// server, and returns its EditURL.
+func addFakeEntry(t *testing.T, s *hatenatest.Server, title, customPath string) string {
+	t.Helper()
+	return addFakeAtomEntry(t, s, &atom.Entry{
+		Title:     title,
+		Content:   atom.Content{Content: title + "\n"},
+		CustomURL: customPath,
+	})
+}
+
+// addFakeAtomEntry is like addFakeEntry but adds e as a published entry with the
+// dates set to fakeEntryDate.
+func addFakeAtomEntry(t *testing.T, s *hatenatest.Server, e *atom.Entry) string {
+	t.Helper()
+	d := fakeEntryDate
+	e.Updated, e.Edited = &d, &d
+	ae, err := s.AddEntry(e, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ae.Links.Find("edit").Href
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/x-motemen/blogsync/atom"
+)
+
+const (
+	// imageSyntaxFotolife rewrites images to the Hatena syntax [f:id:...:image]
+	// as the editor of Hatena Blog does
+	imageSyntaxFotolife = "fotolife"
+	// imageSyntaxURL rewrites the paths of images to the URLs of uploaded ones
+	imageSyntaxURL = "url"
+)
+
+// fotolifeFolder is the folder of Hatena Fotolife in which images are uploaded,
+// which is the same as the one used by the editor of Hatena Blog.
+const fotolifeFolder = "Hatena Blog"
+
+var markdownImageReg = regexp.MustCompile(`!\[([^\]]*)\]\(\s*<?([^)\s>]+)>?(\s+"[^"]*")?\s*\)`)
+
+// isLocalImageRef reports whether ref in the Markdown image is a relative path
+// to a local file.
+func isLocalImageRef(ref string) bool {
+	u, err := url.Parse(ref)
+	return err == nil && u.Scheme == "" && u.Host == "" && u.Path != "" &&
+		!strings.HasPrefix(u.Path, "/")
+}
+
+// uploadImages uploads the local images referenced by relative paths in the
+// Markdown content of e to Hatena Fotolife, and rewrites the references to the
+// uploaded ones. Images in code blocks are left as is. The uploaded images are
+// cached by their content, so that the same image is not uploaded again.
+func (b *broker) uploadImages(ctx context.Context, e *entry) error {
+	if e.localPath == "" || e.ContentType != contentTypeMarkdown {
+		return nil
+	}
+	dir := filepath.Dir(e.localPath)
+
+	var err error
+	lines := strings.SplitAfter(e.Content, "\n")
+	inFence := false
+	for i, line := range lines {
+		if t := strings.TrimSpace(line); strings.HasPrefix(t, "```") || strings.HasPrefix(t, "~~~") {
+			inFence = !inFence
+			continue
+		}
+		if inFence {
+			continue
+		}
+		lines[i] = markdownImageReg.ReplaceAllStringFunc(line, func(m string) string {
+			sm := markdownImageReg.FindStringSubmatch(m)
+			if err != nil || !isLocalImageRef(sm[2]) {
+				return m
+			}
+			p, uerr := url.PathUnescape(sm[2])
+			if uerr != nil {
+				return m
+			}
+			var is *imageState
+			is, err = b.uploadImage(ctx, filepath.Join(dir, filepath.FromSlash(p)))
+			if err != nil || is == nil {
+				return m
+			}
+			if b.ImageSyntax == imageSyntaxURL || is.Syntax == "" {
+				return "![" + sm[1] + "](" + is.URL + sm[3] + ")"
+			}
+			return "[" + is.Syntax + "]"
+		})
+	}
+	if err != nil {
+		return err
+	}
+	e.Content = strings.Join(lines, "")
+	return nil
+}
+
+// uploadImage uploads the image at path unless it has been uploaded, and returns
+// the record of it. It returns nil in the dry run mode.
+func (b *broker) uploadImage(ctx context.Context, path string) (*imageState, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the image: %w", err)
+	}
+	hash := contentHash(data)
+	if is := b.syncState().image(hash); is != nil {
+		return is, nil
+	}
+	contentType := http.DetectContentType(data)
+	if !strings.HasPrefix(contentType, "image/") {
+		return nil, fmt.Errorf("%s is not an image: %s", path, contentType)
+	}
+	endpoint := b.fotolifeEndpoint()
+	if dryRun {
+		b.plan("POST %s (%s)", endpoint, path)
+		return nil, nil
+	}
+
+	img := atom.NewImage(filepath.Base(path), contentType, data)
+	img.Folder = fotolifeFolder
+	uploaded, err := b.Client.PostImageContext(ctx, endpoint, img)
+	if err != nil {
+		return nil, b.apiError(err)
+	}
+	if uploaded.Syntax == "" || uploaded.ImageURL == "" {
+		return nil, fmt.Errorf("unexpected response for the uploaded image %s", path)
+	}
+	is := &imageState{Syntax: uploaded.Syntax, URL: uploaded.ImageURL}
+	b.syncState().setImage(hash, is)
+	b.logf("upload", "%s -> %s", path, is.Syntax)
+	return is, nil
+}
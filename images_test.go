@@ -0,0 +1,73 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestUploadImages(t *testing.T) {
+	s, dir := setupFakeBlog(t)
+	if err := appendFile(filepath.Join(dir, "blogsync.yaml"),
+		"  fotolife_endpoint: "+s.FotolifeEndpoint()+"\n"); err != nil {
+		t.Fatal(err)
+	}
+	var editURLs []string
+	for _, p := range []string{"first", "second"} {
+		editURLs = append(editURLs, addFakeEntry(t, s, p, p))
+	}
+
+	blogsync := blogsyncApp(newApp())
+	if _, err := blogsync("pull"); err != nil {
+		t.Fatal(err)
+	}
+	png := "\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR"
+	if err := os.MkdirAll(filepath.Join(dir, "entry", "img"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "entry", "img", "foo.png"), []byte(png), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	const body = "![foo](./img/foo.png)\n![remote](https://example.com/bar.png)\n```\n![code](./img/foo.png)\n```\n"
+	push := func(name string) string {
+		t.Helper()
+		entryFile := filepath.Join(dir, "entry", name+".md")
+		if err := appendFile(entryFile, body); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := blogsync("push", entryFile); err != nil {
+			t.Fatal(err)
+		}
+		return entryFile
+	}
+
+	entryFile := push("first")
+	images := s.Images()
+	if len(images) != 1 {
+		t.Fatalf("an image should be uploaded: %d", len(images))
+	}
+	if images[0].Folder != fotolifeFolder || images[0].Title != "foo.png" {
+		t.Errorf("unexpected image: %+v", images[0])
+	}
+	expect := "first\n[" + images[0].Syntax + "]\n![remote](https://example.com/bar.png)\n```\n![code](./img/foo.png)\n```\n"
+	if g := s.Entry(editURLs[0]).Content.Content; g != expect {
+		t.Errorf("got:\n%s\nwant:\n%s", g, expect)
+	}
+	if bb, err := os.ReadFile(entryFile); err != nil || !strings.Contains(string(bb), "["+images[0].Syntax+"]") {
+		t.Errorf("local file should be rewritten: %s, %v", bb, err)
+	}
+
+	t.Log("uploaded images are cached")
+	if err := appendFile(filepath.Join(dir, "blogsync.yaml"), "  image_syntax: url\n"); err != nil {
+		t.Fatal(err)
+	}
+	push("second")
+	if n := len(s.Images()); n != 1 {
+		t.Errorf("the same image should not be uploaded again: %d", n)
+	}
+	if g := s.Entry(editURLs[1]).Content.Content; !strings.HasPrefix(g, "second\n![foo]("+images[0].ImageURL+")\n") {
+		t.Errorf("image should be rewritten to the URL: %s", g)
+	}
+}
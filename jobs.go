@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+)
+
+// jobSlots is the semaphore limiting the number of running jobs. It is shared by
+// the nested runJobs through the context of the jobs.
+type jobSlots chan struct{}
+
+type jobSlotsKey struct{}
+
+func jobSlotsFromContext(ctx context.Context) jobSlots {
+	slots, _ := ctx.Value(jobSlotsKey{}).(jobSlots)
+	return slots
+}
+
+// runJobs calls fn for each of n jobs with at most jobs goroutines at a time.
+// Each job gets its own output buffer, which is flushed to parent (or the logger
+// and w if parent is nil) in the order of the jobs, so that the output is the
+// same as running them sequentially. No new job is started after an error, and
+// the first error in the order is returned after all running jobs finish.
+//
+// When runJobs is called in a job of another runJobs, the jobs share the slots
+// of the outer ones, so that at most jobs goroutines run in total. The calling
+// job gives up its slot while waiting for the nested jobs.
+//
+// If jobs is less than 2, the jobs run sequentially without buffering.
+func runJobs(ctx context.Context, jobs, n int, parent *outputBuffer, w io.Writer,
+	fn func(ctx context.Context, i int, out *outputBuffer) error) error {
+	if jobs < 2 || n < 2 {
+		for i := 0; i < n; i++ {
+			if err := fn(withOutputBuffer(ctx, parent), i, parent); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	sem := jobSlotsFromContext(ctx)
+	if sem == nil {
+		sem = make(jobSlots, jobs)
+		ctx = context.WithValue(ctx, jobSlotsKey{}, sem)
+	} else {
+		<-sem
+		defer func() { sem <- struct{}{} }()
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	outs := make([]*outputBuffer, n)
+	errs := make([]error, n)
+	dones := make([]chan struct{}, n)
+	for i := range outs {
+		outs[i] = &outputBuffer{}
+		dones[i] = make(chan struct{})
+	}
+	go func() {
+		for i := 0; i < n; i++ {
+			select {
+			case sem <- struct{}{}:
+			case <-ctx.Done():
+				for ; i < n; i++ {
+					close(dones[i])
+				}
+				return
+			}
+			go func(i int) {
+				defer func() {
+					<-sem
+					close(dones[i])
+				}()
+				if errs[i] = fn(withOutputBuffer(ctx, outs[i]), i, outs[i]); errs[i] != nil {
+					cancel()
+				}
+			}(i)
+		}
+	}()
+
+	var firstErr, canceled error
+	for i := 0; i < n; i++ {
+		<-dones[i]
+		outs[i].flush(parent, w)
+		switch err := errs[i]; {
+		case err == nil:
+		case errors.Is(err, context.Canceled):
+			// Jobs may be canceled due to the error of a later job
+			if canceled == nil {
+				canceled = err
+			}
+		case firstErr == nil:
+			firstErr = err
+		}
+	}
+	if firstErr == nil {
+		return canceled
+	}
+	return firstErr
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRunJobs(t *testing.T) {
+	for _, jobs := range []int{1, 4} {
+		t.Run(fmt.Sprintf("jobs=%d", jobs), func(t *testing.T) {
+			var buf bytes.Buffer
+			err := runJobs(context.Background(), jobs, 10, nil, &buf, func(ctx context.Context, i int, out *outputBuffer) error {
+				// later jobs finish earlier
+				time.Sleep(time.Duration(10-i) * time.Millisecond)
+				// out is nil when the jobs run sequentially
+				var w io.Writer = &buf
+				if out != nil {
+					w = out
+				}
+				fmt.Fprintf(w, "%d\n", i)
+				return nil
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if expect := "0\n1\n2\n3\n4\n5\n6\n7\n8\n9\n"; buf.String() != expect {
+				t.Errorf("got %q, want %q", buf.String(), expect)
+			}
+		})
+	}
+
+	t.Run("error", func(t *testing.T) {
+		var buf bytes.Buffer
+		started := make([]bool, 100)
+		err := runJobs(context.Background(), 2, len(started), nil, &buf, func(ctx context.Context, i int, out *outputBuffer) error {
+			started[i] = true
+			switch i {
+			case 1:
+				time.Sleep(10 * time.Millisecond)
+				return fmt.Errorf("error %d", i)
+			case 2:
+				<-ctx.Done()
+				return ctx.Err()
+			}
+			fmt.Fprintf(out, "%d\n", i)
+			return nil
+		})
+		if err == nil || err.Error() != "error 1" {
+			t.Errorf("unexpected error: %v", err)
+		}
+		if started[len(started)-1] {
+			t.Errorf("jobs should not be started after an error")
+		}
+		if expect := "0\n"; buf.String() != expect {
+			t.Errorf("got %q, want %q", buf.String(), expect)
+		}
+	})
+
+	t.Run("nested", func(t *testing.T) {
+		var buf bytes.Buffer
+		var running, maxRunning int32
+		work := func() {
+			n := atomic.AddInt32(&running, 1)
+			for {
+				m := atomic.LoadInt32(&maxRunning)
+				if n <= m || atomic.CompareAndSwapInt32(&maxRunning, m, n) {
+					break
+				}
+			}
+			time.Sleep(5 * time.Millisecond)
+			atomic.AddInt32(&running, -1)
+		}
+		err := runJobs(context.Background(), 2, 3, nil, &buf, func(ctx context.Context, i int, out *outputBuffer) error {
+			work()
+			return runJobs(ctx, 2, 4, out, nil, func(ctx context.Context, j int, out *outputBuffer) error {
+				work()
+				fmt.Fprintf(out, "%d-%d\n", i, j)
+				return nil
+			})
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n := atomic.LoadInt32(&maxRunning); n > 2 {
+			t.Errorf("blogs and entries should share the limit: %d jobs ran at a time", n)
+		}
+		expect := "0-0\n0-1\n0-2\n0-3\n1-0\n1-1\n1-2\n1-3\n2-0\n2-1\n2-2\n2-3\n"
+		if buf.String() != expect {
+			t.Errorf("got %q, want %q", buf.String(), expect)
+		}
+	})
+}
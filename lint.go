@@ -0,0 +1,271 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// diagnostic is a problem in an entry file found by lintEntry.
+type diagnostic struct {
+	path string
+	line int
+	// warning is true for problems which do not prevent the entry from being pushed
+	warning bool
+	message string
+}
+
+func (d *diagnostic) String() string {
+	pos := d.path
+	if d.line > 0 {
+		pos = fmt.Sprintf("%s:%d", d.path, d.line)
+	}
+	if d.warning {
+		return fmt.Sprintf("%s: warning: %s", pos, d.message)
+	}
+	return fmt.Sprintf("%s: %s", pos, d.message)
+}
+
+func hasLintErrors(ds []*diagnostic) bool {
+	for _, d := range ds {
+		if !d.warning {
+			return true
+		}
+	}
+	return false
+}
+
+var (
+	frontMatterKeyReg = regexp.MustCompile(`^([^\s#'"\-][^:]*?)\s*:(?:\s|$)`)
+	yamlErrorLineReg  = regexp.MustCompile(`line (\d+): (.*)`)
+	customPathReg     = regexp.MustCompile(`[\s?#%\\]`)
+)
+
+// lintEntry checks the entry file content at path and returns the problems in
+// the order of lines. bc is the config of the blog the file belongs to, which
+// may be nil if it is unknown.
+func lintEntry(path, content string, bc *blogConfig) []*diagnostic {
+	var ds []*diagnostic
+	report := func(line int, warning bool, format string, args ...interface{}) {
+		ds = append(ds, &diagnostic{path: path, line: line, warning: warning, message: fmt.Sprintf(format, args...)})
+	}
+	defer func() {
+		sort.SliceStable(ds, func(i, j int) bool { return ds[i].line < ds[j].line })
+	}()
+
+	lines := strings.Split(content, "\n")
+	for i, l := range lines {
+		if conflictMarkerReg.MatchString(l) {
+			report(i+1, false, "unresolved conflict marker")
+		}
+	}
+	if !strings.HasPrefix(content, "---\n") {
+		report(1, true, "no front matter, the entry will be posted without title")
+		return ds
+	}
+	// The front matter starts at line 2 after the delimiter
+	end := 0
+	for i := 1; i < len(lines); i++ {
+		if lines[i] == "---" {
+			end = i
+			break
+		}
+	}
+	if end == 0 {
+		report(1, false, "front matter is not closed with ---")
+		return ds
+	}
+	frontMatter := strings.Join(lines[1:end], "\n")
+
+	var items yaml.MapSlice
+	if err := yaml.Unmarshal([]byte(frontMatter), &items); err != nil {
+		line := 1
+		msg := strings.TrimPrefix(err.Error(), "yaml: ")
+		if m := yamlErrorLineReg.FindStringSubmatch(msg); m != nil {
+			line, _ = strconv.Atoi(m[1])
+			line++
+			msg = m[2]
+		}
+		report(line, false, "invalid front matter: %s", msg)
+		return ds
+	}
+
+	keyLines := map[string][]int{}
+	var keyStarts []int
+	for i, l := range lines[1:end] {
+		if m := frontMatterKeyReg.FindStringSubmatch(l); m != nil {
+			keyLines[m[1]] = append(keyLines[m[1]], i+2)
+			keyStarts = append(keyStarts, i+2)
+		}
+	}
+	// rawItem returns the lines of the item at line, so that it is decoded in
+	// the same way as the whole front matter
+	rawItem := func(line int) []byte {
+		next := end + 1
+		for _, l := range keyStarts {
+			if l > line {
+				next = l
+				break
+			}
+		}
+		return []byte(strings.Join(lines[line-1:next-1], "\n"))
+	}
+	// lineOf returns the line of key, or the line of the opening delimiter if
+	// key is not found by frontMatterKeyReg, such as quoted or missing ones
+	lineOf := func(key string) int {
+		if ls := keyLines[key]; len(ls) > 0 {
+			return ls[0]
+		}
+		return 1
+	}
+
+	eh := &entryHeader{}
+	seen := map[string]bool{}
+	for _, item := range items {
+		key := fmt.Sprint(item.Key)
+		line := lineOf(key)
+		if ls := keyLines[key]; len(ls) > 1 {
+			keyLines[key] = ls[1:]
+		}
+		if seen[key] {
+			report(line, false, "duplicate key %q", key)
+			continue
+		}
+		seen[key] = true
+
+		if !entryHeaderKeys[key] {
+			if known := similarHeaderKey(key); known != "" {
+				report(line, false, "unknown key %q, did you mean %q?", key, known)
+			}
+			continue
+		}
+		if line == 1 {
+			continue
+		}
+		// Decode the item alone to find the error of each key
+		if err := yaml.Unmarshal(rawItem(line), eh); err != nil {
+			msg := strings.TrimPrefix(err.Error(), "yaml: unmarshal errors:\n")
+			if m := yamlErrorLineReg.FindStringSubmatch(msg); m != nil {
+				msg = m[2]
+			}
+			report(line, false, "invalid %s: %s", key, msg)
+			continue
+		}
+		if key == "Date" {
+			if s, ok := item.Value.(string); ok && !strings.ContainsAny(s, "Zz+") && strings.Count(s, "-") < 3 {
+				report(line, true, "Date %q has no time zone and is taken as UTC", s)
+			}
+		}
+	}
+
+	if strings.TrimSpace(eh.Title) == "" {
+		report(lineOf("Title"), true, "empty Title")
+	}
+	dup := map[string]bool{}
+	for _, c := range eh.Category {
+		if dup[c] {
+			report(lineOf("Category"), true, "duplicate category %q", c)
+		}
+		dup[c] = true
+	}
+	if eh.CustomPath != "" {
+		cp, line := eh.CustomPath, lineOf("CustomPath")
+		switch {
+		case customPathReg.MatchString(cp):
+			report(line, false, "CustomPath %q contains invalid characters", cp)
+		case strings.HasPrefix(cp, "/") || strings.HasSuffix(cp, "/"):
+			report(line, false, "CustomPath %q must not start or end with /", cp)
+		case hasDotSegment(cp):
+			report(line, false, "CustomPath %q must not contain empty, . or .. segments", cp)
+		case bc != nil && eh.EditURL != "":
+			_, entryPath := bc.extractEntryPath(path)
+			if entryPath != "" && !strings.HasPrefix(entryPath, draftDir) && entryPath != cp {
+				report(line, true, "CustomPath (%s) is overridden by the file path (%s) on push", cp, entryPath)
+			}
+		}
+	}
+	return ds
+}
+
+func hasDotSegment(p string) bool {
+	for _, seg := range strings.Split(p, "/") {
+		if seg == "" || seg == "." || seg == ".." {
+			return true
+		}
+	}
+	return false
+}
+
+// similarHeaderKey returns the known key which key is likely a typo of, or "".
+func similarHeaderKey(key string) string {
+	var keys []string
+	for k := range entryHeaderKeys {
+		keys = append(keys, k)
+	}
+	return similarKey(key, keys)
+}
+
+// similarKey returns the one of keys which key is likely a typo of, or "".
+func similarKey(key string, keys []string) string {
+	keys = append([]string(nil), keys...)
+	sort.Strings(keys)
+	for _, k := range keys {
+		maxDist := 2
+		if len(k) <= 4 {
+			maxDist = 1
+		}
+		if editDistance(strings.ToLower(key), strings.ToLower(k)) <= maxDist {
+			return k
+		}
+	}
+	return ""
+}
+
+// editDistance returns the Levenshtein distance between a and b.
+func editDistance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		cur := make([]int, len(rb)+1)
+		cur[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev = cur
+	}
+	return prev[len(rb)]
+}
+
+// lintFile reads and checks the entry file at path, which is shown as name in
+// the diagnostics.
+func lintFile(conf *config, path, name string) ([]*diagnostic, error) {
+	bb, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var bc *blogConfig
+	if editURL := editURLFromFile(path); editURL != "" {
+		if blogID, err := (&entryHeader{EditURL: editURL}).blogID(); err == nil {
+			bc = conf.Get(blogID)
+		}
+	} else {
+		bc = conf.detectBlogConfig(path)
+	}
+	ds := lintEntry(path, string(bb), bc)
+	for _, d := range ds {
+		d.path = name
+	}
+	return ds, nil
+}
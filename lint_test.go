@@ -0,0 +1,196 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLintEntry(t *testing.T) {
+	testCases := []struct {
+		name    string
+		content string
+		expect  []string
+	}{
+		{
+			name: "valid",
+			content: `---
+Title: valid
+Category:
+- blog
+Date: 2020-01-02T03:04:05+09:00
+Draft: yes
+Series: blogsync
+---
+
+body
+`,
+		},
+		{
+			name: "typo of keys",
+			content: `---
+Title: typo
+Catgory:
+- blog
+draft: true
+Series: blogsync
+---
+`,
+			expect: []string{
+				`e.md:3: unknown key "Catgory", did you mean "Category"?`,
+				`e.md:5: unknown key "draft", did you mean "Draft"?`,
+			},
+		},
+		{
+			name: "invalid values",
+			content: `---
+Title: invalid
+Date: 2020-13-02T03:04:05+09:00
+Draft: draft
+---
+`,
+			expect: []string{
+				`e.md:3: invalid Date: parsing time "2020-13-02T03:04:05+09:00": month out of range`,
+				"e.md:4: invalid Draft: cannot unmarshal !!str `draft` into bool",
+			},
+		},
+		{
+			name: "warnings",
+			content: `---
+Title: ""
+Category: [blog, go, blog]
+Date: 2020-01-02 03:04:05
+---
+`,
+			expect: []string{
+				`e.md:2: warning: empty Title`,
+				`e.md:3: warning: duplicate category "blog"`,
+				`e.md:4: warning: Date "2020-01-02 03:04:05" has no time zone and is taken as UTC`,
+			},
+		},
+		{
+			name: "invalid custom path",
+			content: `---
+Title: custom path
+CustomPath: foo bar
+---
+`,
+			expect: []string{`e.md:3: CustomPath "foo bar" contains invalid characters`},
+		},
+		{
+			name: "dot segments in custom path",
+			content: `---
+Title: custom path
+CustomPath: foo/../bar
+---
+`,
+			expect: []string{`e.md:3: CustomPath "foo/../bar" must not contain empty, . or .. segments`},
+		},
+		{
+			name: "duplicate key",
+			content: `---
+Title: a
+Title: b
+---
+`,
+			expect: []string{`e.md:3: duplicate key "Title"`},
+		},
+		{
+			name: "invalid yaml",
+			content: `---
+Title: a
+Category: [
+---
+`,
+			expect: []string{`e.md:3: invalid front matter: did not find expected node content`},
+		},
+		{
+			name: "conflict markers",
+			content: `---
+Title: conflict
+---
+
+<<<<<<< local
+local
+=======
+remote
+>>>>>>> remote
+`,
+			expect: []string{`e.md:5: unresolved conflict marker`},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var got []string
+			for _, d := range lintEntry("e.md", tc.content, nil) {
+				got = append(got, d.String())
+			}
+			if !reflect.DeepEqual(got, tc.expect) {
+				t.Errorf("got:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(tc.expect, "\n"))
+			}
+		})
+	}
+}
+
+func TestLintCustomPath(t *testing.T) {
+	bc := &blogConfig{BlogID: "example.com", LocalRoot: "/blog"}
+	content := `---
+Title: moved
+EditURL: https://blog.hatena.ne.jp/example/example.com/atom/entry/1
+CustomPath: old
+---
+`
+	ds := lintEntry(filepath.FromSlash("/blog/example.com/entry/new.md"), content, bc)
+	if len(ds) != 1 || !ds[0].warning || ds[0].line != 4 {
+		t.Fatalf("mismatch of CustomPath should be warned: %v", ds)
+	}
+}
+
+func TestLintCommand(t *testing.T) {
+	_, dir := setupFakeBlog(t)
+	entryDir := filepath.Join(dir, "entry")
+	if err := os.MkdirAll(entryDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	valid := filepath.Join(entryDir, "valid.md")
+	if err := os.WriteFile(valid, []byte("---\nTitle: valid\n---\n\nvalid\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, f := range []string{"README.md", filepath.Join("site", "index.html")} {
+		f = filepath.Join(dir, f)
+		if err := os.MkdirAll(filepath.Dir(f), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(f, []byte("not an entry\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	blogsync := blogsyncApp(newApp())
+	if out, err := blogsync("lint"); err != nil {
+		t.Errorf("lint should pass: %s\n%s", err, out)
+	}
+
+	invalid := filepath.Join(entryDir, "invalid.md")
+	if err := os.WriteFile(invalid, []byte("---\nTitle: invalid\nCatgory: [blog]\n---\n\ninvalid\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	out, err := blogsync("lint", invalid)
+	if err == nil {
+		t.Errorf("lint should fail")
+	}
+	if expect := invalid + `:3: unknown key "Catgory", did you mean "Category"?`; out != filepath.ToSlash(expect) {
+		t.Errorf("got %q, want %q", out, expect)
+	}
+
+	if _, err := blogsync("push", valid, invalid); err == nil || !strings.Contains(err.Error(), "invalid front matter") {
+		t.Errorf("push should be rejected: %v", err)
+	}
+	if e, err := entryFromFile(valid); err != nil || e.EditURL != "" {
+		t.Errorf("nothing should be pushed: %v, %+v", err, e)
+	}
+}
@@ -1,8 +1,11 @@
 package main
 
 import (
+	"context"
 	"fmt"
+	"io"
 	"os"
+	"sync"
 
 	"github.com/motemen/go-colorine"
 )
@@ -12,11 +15,16 @@ var logger *colorine.Logger
 func init() {
 	logger = &colorine.Logger{
 		Prefixes: colorine.Prefixes{
-			"http":  colorine.Verbose,
-			"store": colorine.Info,
-			"warn":  colorine.Warn,
-			"error": colorine.Error,
-			"":      colorine.Verbose,
+			"http":     colorine.Verbose,
+			"store":    colorine.Info,
+			"warn":     colorine.Warn,
+			"conflict": colorine.Warn,
+			"prune":    colorine.Info,
+			"trash":    colorine.Info,
+			"upload":   colorine.Info,
+			"download": colorine.Info,
+			"error":    colorine.Error,
+			"":         colorine.Verbose,
 		}}
 	logger.SetOutput(os.Stderr)
 }
@@ -24,3 +32,71 @@ func init() {
 func logf(prefix, pattern string, args ...interface{}) {
 	logger.Log(prefix, fmt.Sprintf(pattern, args...))
 }
+
+// outputBuffer buffers the log lines and the output of a job running concurrently
+// with others, so that they can be flushed in the order of the jobs.
+type outputBuffer struct {
+	mu      sync.Mutex
+	records []outputRecord
+}
+
+type outputRecord struct {
+	prefix, message string
+	// output is the data written to the output writer instead of a log line
+	output []byte
+}
+
+func (ob *outputBuffer) logf(prefix, pattern string, args ...interface{}) {
+	ob.mu.Lock()
+	defer ob.mu.Unlock()
+	ob.records = append(ob.records, outputRecord{prefix: prefix, message: fmt.Sprintf(pattern, args...)})
+}
+
+// Write buffers the output, which is written to the output writer on flush.
+func (ob *outputBuffer) Write(p []byte) (int, error) {
+	ob.mu.Lock()
+	defer ob.mu.Unlock()
+	ob.records = append(ob.records, outputRecord{output: append([]byte(nil), p...)})
+	return len(p), nil
+}
+
+// flush moves the buffered records to parent, or outputs them to the logger and
+// w if parent is nil.
+func (ob *outputBuffer) flush(parent *outputBuffer, w io.Writer) {
+	ob.mu.Lock()
+	records := ob.records
+	ob.records = nil
+	ob.mu.Unlock()
+
+	if parent != nil {
+		parent.mu.Lock()
+		parent.records = append(parent.records, records...)
+		parent.mu.Unlock()
+		return
+	}
+	for _, r := range records {
+		if r.output != nil {
+			w.Write(r.output)
+		} else {
+			logger.Log(r.prefix, r.message)
+		}
+	}
+}
+
+type outputBufferKey struct{}
+
+func withOutputBuffer(ctx context.Context, ob *outputBuffer) context.Context {
+	if ob == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, outputBufferKey{}, ob)
+}
+
+// logfContext logs to the output buffer in ctx if any.
+func logfContext(ctx context.Context, prefix, pattern string, args ...interface{}) {
+	if ob, ok := ctx.Value(outputBufferKey{}).(*outputBuffer); ok {
+		ob.logf(prefix, pattern, args...)
+		return
+	}
+	logf(prefix, pattern, args...)
+}
@@ -10,10 +10,10 @@ import (
 
 func init() {
 	loghttp.DefaultLogRequest = func(req *http.Request) {
-		logf(req.Method, "---> %s", req.URL)
+		logfContext(req.Context(), req.Method, "---> %s", req.URL)
 	}
 
 	loghttp.DefaultLogResponse = func(resp *http.Response) {
-		logf(fmt.Sprintf("%d", resp.StatusCode), "<--- %s", resp.Request.URL)
+		logfContext(resp.Request.Context(), fmt.Sprintf("%d", resp.StatusCode), "<--- %s", resp.Request.URL)
 	}
 }
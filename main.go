@@ -1,12 +1,18 @@
 package main
 
 import (
+	"bufio"
+	"context"
+	"errors"
 	"fmt"
+	"io"
 	"os"
+	"os/signal"
 	"path/filepath"
 	"regexp"
 	"sort"
 	"strings"
+	"syscall"
 	"time"
 
 	"github.com/urfave/cli/v2"
@@ -14,6 +20,23 @@ import (
 
 var errCommandHelp = fmt.Errorf("command help shown")
 
+// exitStatusError is an error which makes blogsync exit with the specific status.
+type exitStatusError struct {
+	status int
+	err    error
+}
+
+func (e *exitStatusError) Error() string {
+	return e.err.Error()
+}
+
+func (e *exitStatusError) Unwrap() error {
+	return e.err
+}
+
+// errDiffFound is returned by diff command when differences are found.
+var errDiffFound = &exitStatusError{status: 1, err: fmt.Errorf("differences found")}
+
 func newApp() *cli.App {
 	app := cli.NewApp()
 	app.Commands = []*cli.Command{
@@ -21,8 +44,16 @@ func newApp() *cli.App {
 		commandFetch,
 		commandPush,
 		commandPost,
+		commandNew,
 		commandList,
 		commandRemove,
+		commandRestore,
+		commandStatus,
+		commandDiff,
+		commandReindex,
+		commandLint,
+		commandAuth,
+		commandConfig,
 	}
 	app.Flags = []cli.Flag{
 		&cli.StringFlag{
@@ -36,17 +67,45 @@ func newApp() *cli.App {
 				return os.Chdir(wdir)
 			},
 		},
+		&cli.BoolFlag{
+			Name:  "dry-run",
+			Usage: "print the requests changing remote entries and the changes of local files instead of doing them",
+		},
+		&cli.DurationFlag{
+			Name:    "timeout",
+			Value:   defaultRequestTimeout,
+			Usage:   "timeout of each API request, 0 means no timeout",
+			EnvVars: []string{"BLOGSYNC_TIMEOUT"},
+		},
+	}
+	app.Before = func(c *cli.Context) error {
+		requestTimeout = c.Duration("timeout")
+		dryRun = c.Bool("dry-run")
+		return nil
+	}
+	app.After = func(c *cli.Context) error {
+		return saveSyncStates()
 	}
 	app.Version = fmt.Sprintf("%s (%s)", version, revision)
 	return app
 }
 
 func main() {
-	if err := newApp().Run(os.Args); err != nil {
-		if err != errCommandHelp {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	err := newApp().RunContext(ctx, os.Args)
+	stop()
+	if err != nil {
+		if err != errCommandHelp && err != errDiffFound {
 			logf("error", "%s", err)
 		}
-		os.Exit(1)
+		status := 1
+		var ee *exitStatusError
+		if errors.As(err, &ee) {
+			status = ee.status
+		} else if errors.Is(err, context.Canceled) {
+			status = 130
+		}
+		os.Exit(status)
 	}
 }
 
@@ -56,6 +115,20 @@ var commandPull = &cli.Command{
 	Flags: []cli.Flag{
 		&cli.BoolFlag{Name: "no-drafts"},
 		&cli.BoolFlag{Name: "only-drafts"},
+		&cli.BoolFlag{Name: "full", Usage: "fetch all entries instead of ones edited since the last pull"},
+		&cli.GenericFlag{
+			Name:  "prune",
+			Value: new(pruneMode),
+			Usage: "delete local files of entries deleted on remote, or move them into the archive directory with --prune=archive",
+		},
+		&cli.BoolFlag{Name: "with-html", Usage: "store the HTML rendered by Hatena Blog next to each entry as *.formatted.html"},
+		&cli.BoolFlag{Name: "assets", Usage: "download the images referenced in entries into the assets directory under the local root"},
+		&cli.IntFlag{
+			Name:    "jobs",
+			Aliases: []string{"j"},
+			Value:   1,
+			Usage:   "number of blogs and entries to process concurrently",
+		},
 	},
 	Action: func(c *cli.Context) error {
 		conf, err := loadConfiguration()
@@ -72,44 +145,55 @@ var commandPull = &cli.Command{
 			return errCommandHelp
 		}
 
-		for _, blog := range blogs {
-			blogConfig := conf.Get(blog)
-			if blogConfig == nil {
+		blogConfigs := make([]*blogConfig, len(blogs))
+		for i, blog := range blogs {
+			blogConfigs[i] = conf.Get(blog)
+			if blogConfigs[i] == nil {
 				return fmt.Errorf("blog not found: %s", blog)
 			}
+		}
 
-			b := newBroker(blogConfig, c.App.Writer)
+		jobs := c.Int("jobs")
+		published, drafts := !c.Bool("only-drafts"), !c.Bool("no-drafts")
+		prune := c.Generic("prune").(*pruneMode).String()
+		return runJobs(c.Context, jobs, len(blogConfigs), nil, c.App.Writer, func(ctx context.Context, i int, out *outputBuffer) error {
+			b := newBroker(blogConfigs[i], c.App.Writer).withOutput(out)
+			b.withHTML = c.Bool("with-html")
+			b.withAssets = c.Bool("assets")
 			localEntryMap := b.buildLocalEntryMap()
-			remoteEntries, err := b.FetchRemoteEntries(
-				!c.Bool("only-drafts"), !c.Bool("no-drafts"))
+			var since time.Time
+			if !c.Bool("full") && prune == "" {
+				since = b.syncState().LastPull
+			}
+			remoteEntries, err := b.FetchRemoteEntriesSince(ctx, published, drafts, since)
 			if err != nil {
 				return err
 			}
 
-			for _, re := range remoteEntries {
-				path := b.LocalPath(re)
-				if oldPath, ok := localEntryMap[re.EditURL]; ok && oldPath != path {
-					// Entry has been renamed locally
-					localMtime, _ := modTime(oldPath)
-					if localMtime.After(*re.LastModified) {
-						logf("warn", "local file is newer, skipping remote: %s", oldPath)
-						continue
-					}
-					logf("store", "renamed: %s -> %s", oldPath, path)
-					if _, err := b.StoreFresh(re, path); err != nil {
-						return err
-					}
-					if err := os.Remove(oldPath); err != nil && !os.IsNotExist(err) {
-						return err
-					}
-				} else {
-					if _, err := b.StoreFresh(re, path); err != nil {
+			err = runJobs(ctx, jobs, len(remoteEntries), out, b.writer, func(ctx context.Context, i int, out *outputBuffer) error {
+				re := remoteEntries[i]
+				b := b.withOutput(out)
+				if err := b.pullEntry(re, localEntryMap[re.EditURL]); err != nil {
+					return err
+				}
+				return b.pullAssets(ctx, re)
+			})
+			if err != nil {
+				return err
+			}
+			if published && drafts {
+				// Deleted entries can be detected only from the full listing
+				if since.IsZero() {
+					if err := b.PruneEntries(localEntryMap, remoteEntries, prune); err != nil {
 						return err
 					}
 				}
+				b.syncState().updateLastPull(remoteEntries)
+			} else if prune != "" {
+				b.logf("warn", "--prune is ignored with --no-drafts or --only-drafts")
 			}
-		}
-		return nil
+			return nil
+		})
 	},
 }
 
@@ -140,7 +224,7 @@ var commandFetch = &cli.Command{
 				return fmt.Errorf("cannot find blog for %s", path)
 			}
 			b := newBroker(bc, c.App.Writer)
-			re, err := asEntry(b.GetEntry(e.EditURL))
+			re, err := b.fetchEntry(c.Context, path, e.EditURL)
 			if err != nil {
 				return err
 			}
@@ -187,14 +271,50 @@ var commandPush = &cli.Command{
 			return err
 		}
 
-		for _, path := range c.Args().Slice() {
-			if !filepath.IsAbs(path) {
-				var err error
-				path, err = filepath.Abs(path)
-				if err != nil {
-					return err
+		var paths []string
+		invalid := 0
+		for _, arg := range c.Args().Slice() {
+			path, err := filepath.Abs(arg)
+			if err != nil {
+				return err
+			}
+			if isFormattedHTML(path) {
+				return fmt.Errorf("%s is the HTML rendered by Hatena Blog, which cannot be pushed", path)
+			}
+			if isSidecar(path) {
+				return fmt.Errorf("%s is the remote content of a conflict, which cannot be pushed", path)
+			}
+			bb, err := os.ReadFile(path)
+			if err != nil {
+				return err
+			}
+			if hasConflictMarkers(string(bb)) {
+				return fmt.Errorf("%s has unresolved conflict markers", path)
+			}
+			if sp := sidecarPath(path); fileExists(sp) {
+				return fmt.Errorf("%s has an unresolved conflict, merge the remote content in %s and remove it", path, sp)
+			}
+			ds, err := lintFile(conf, path, arg)
+			if err != nil {
+				return err
+			}
+			for _, d := range ds {
+				if d.warning {
+					logf("warn", "%s", d)
+				} else {
+					logf("error", "%s", d)
 				}
 			}
+			if hasLintErrors(ds) {
+				invalid++
+			}
+			paths = append(paths, path)
+		}
+		if invalid > 0 {
+			return fmt.Errorf("%d file(s) have invalid front matter, nothing is pushed", invalid)
+		}
+
+		for _, path := range paths {
 			entry, err := entryFromFile(path)
 			if err != nil {
 				return err
@@ -219,12 +339,22 @@ var commandPush = &cli.Command{
 				if entryPath == "" {
 					return fmt.Errorf("%q is not a blog entry", path)
 				}
-				entry.CustomPath = entryPath
+				// Files in _draft/ are posted without custom path and stored as
+				// _draft/{entryID} like drafts posted by the post command.
+				isDraftFile := strings.HasPrefix(entryPath, draftDir)
+				if !isDraftFile {
+					entry.CustomPath = entryPath
+				}
 				b := newBroker(bc, c.App.Writer)
-				err = b.PostEntry(entry, false)
+				err = b.PostEntry(c.Context, entry, false)
 				if err != nil {
 					return err
 				}
+				if isDraftFile && (dryRun || editURLFromFile(path) == "") {
+					if err := b.removeFile(path); err != nil {
+						return err
+					}
+				}
 				continue
 			}
 
@@ -239,15 +369,12 @@ var commandPush = &cli.Command{
 			}
 
 			if _, entryPath := bc.extractEntryPath(path); entryPath != "" {
+				// The mismatch of CustomPath and the file path is warned by lintFile above
 				if !strings.HasPrefix(entryPath, draftDir) {
-					if entry.CustomPath != "" && entry.CustomPath != entryPath {
-						logf("warn", "frontmatter CustomPath (%s) is overridden by file path (%s) and will be cleared",
-							entry.CustomPath, entryPath)
-					}
 					entry.CustomPath = entryPath
 				}
 			}
-			_, err = newBroker(bc, c.App.Writer).UploadFresh(entry)
+			_, err = newBroker(bc, c.App.Writer).UploadFresh(c.Context, entry)
 			if err != nil {
 				return err
 			}
@@ -299,7 +426,7 @@ var commandPost = &cli.Command{
 		}
 
 		b := newBroker(blogConfig, c.App.Writer)
-		err = b.PostEntry(entry, c.Bool("page"))
+		err = b.PostEntry(c.Context, entry, c.Bool("page"))
 		if err != nil {
 			return err
 		}
@@ -307,6 +434,71 @@ var commandPost = &cli.Command{
 	},
 }
 
+var commandNew = &cli.Command{
+	Name:      "new",
+	Usage:     "Create a new local entry file",
+	ArgsUsage: "<blogID> [<slug>]",
+	Description: "Creates a file for a new entry at <slug> under the entry directory, or in _draft/\n" +
+		"as a draft if <slug> is omitted, and prints its path. The file is posted by push.",
+	Flags: []cli.Flag{
+		&cli.StringFlag{Name: "title"},
+		&cli.StringSliceFlag{Name: "category", Usage: "category of the entry, can be specified multiple times"},
+		&cli.BoolFlag{Name: "draft"},
+		&cli.BoolFlag{Name: "edit", Aliases: []string{"e"}, Usage: "open the file with $EDITOR"},
+	},
+	Action: func(c *cli.Context) error {
+		blog := c.Args().First()
+		if blog == "" {
+			cli.ShowCommandHelp(c, "new")
+			return errCommandHelp
+		}
+		slug := c.Args().Get(1)
+
+		conf, err := loadConfiguration()
+		if err != nil {
+			return err
+		}
+		bc := conf.Get(blog)
+		if bc == nil {
+			return fmt.Errorf("blog not found: %s", blog)
+		}
+
+		now := time.Now().Truncate(time.Second)
+		path, err := newEntryPath(bc, slug, now)
+		if err != nil {
+			return err
+		}
+		if fileExists(path) {
+			return fmt.Errorf("%s already exists", path)
+		}
+		e := &entry{
+			entryHeader: &entryHeader{
+				Title:    c.String("title"),
+				Category: c.StringSlice("category"),
+				Date:     &now,
+				IsDraft:  c.Bool("draft") || slug == "",
+			},
+			ContentType: contentTypeForPath(path),
+		}
+		content, err := renderNewEntry(bc, e, trimEntryExt(slug))
+		if err != nil {
+			return err
+		}
+		if err := newBroker(bc, c.App.Writer).writeFile(path, []byte(content)); err != nil {
+			return err
+		}
+		if dryRun {
+			return nil
+		}
+		fmt.Fprintln(c.App.Writer, path)
+
+		if c.Bool("edit") {
+			return openEditor(path)
+		}
+		return nil
+	},
+}
+
 var commandList = &cli.Command{
 	Name:  "list",
 	Usage: "List local blogs",
@@ -349,6 +541,11 @@ var commandList = &cli.Command{
 var commandRemove = &cli.Command{
 	Name:  "remove",
 	Usage: "Remove blog entries",
+	Description: "Removes the remote entries and the local files after confirmation. They are saved into\n" +
+		".blogsync/trash/ in the local root, and can be posted again by restore command.",
+	Flags: []cli.Flag{
+		&cli.BoolFlag{Name: "yes", Aliases: []string{"y"}, Usage: "remove without confirmation"},
+	},
 	Action: func(c *cli.Context) error {
 		first := c.Args().First()
 		if first == "" {
@@ -361,6 +558,7 @@ var commandRemove = &cli.Command{
 			return err
 		}
 
+		in := bufio.NewReader(c.App.Reader)
 		for _, path := range c.Args().Slice() {
 			entry, err := entryFromFile(path)
 			if err != nil {
@@ -377,11 +575,402 @@ var commandRemove = &cli.Command{
 				return fmt.Errorf("cannot find blog for %s", path)
 			}
 
-			err = newBroker(bc, c.App.Writer).RemoveEntry(entry)
+			if !c.Bool("yes") && !dryRun {
+				fmt.Fprintf(c.App.ErrWriter, "Remove %q (%s) from %s? [y/N] ", entry.Title, path, blogID)
+				answer, err := in.ReadString('\n')
+				if err != nil && err != io.EOF {
+					return err
+				}
+				if a := strings.ToLower(strings.TrimSpace(answer)); a != "y" && a != "yes" {
+					logf("warn", "skipped: %s", path)
+					continue
+				}
+			}
+
+			err = newBroker(bc, c.App.Writer).RemoveEntry(c.Context, entry)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	},
+}
+
+var commandRestore = &cli.Command{
+	Name:      "restore",
+	Usage:     "Post removed entries again",
+	ArgsUsage: "<path/to/trashed/file>...",
+	Description: "Posts the entries saved in .blogsync/trash/ by remove command again with the same path,\n" +
+		"categories and date. The restored entries get new EditURLs.",
+	Action: func(c *cli.Context) error {
+		first := c.Args().First()
+		if first == "" {
+			cli.ShowCommandHelp(c, "restore")
+			return errCommandHelp
+		}
+
+		conf, err := loadConfiguration()
+		if err != nil {
+			return err
+		}
+
+		for _, path := range c.Args().Slice() {
+			absPath, err := filepath.Abs(path)
+			if err != nil {
+				return err
+			}
+			bc := conf.detectBlogConfig(absPath)
+			if bc == nil {
+				return fmt.Errorf("cannot find blog for %s", path)
+			}
+			if err := newBroker(bc, c.App.Writer).RestoreEntry(c.Context, absPath); err != nil {
+				return err
+			}
+		}
+		return nil
+	},
+}
+
+var commandStatus = &cli.Command{
+	Name:  "status",
+	Usage: "Show differences between local entries and remote",
+	Flags: []cli.Flag{
+		&cli.BoolFlag{Name: "json", Usage: "output in JSON format"},
+	},
+	Action: func(c *cli.Context) error {
+		conf, err := loadConfiguration()
+		if err != nil {
+			return err
+		}
+
+		blogs := c.Args().Slice()
+		if len(blogs) == 0 {
+			blogs = conf.localBlogIDs()
+		}
+		if len(blogs) == 0 {
+			cli.ShowCommandHelp(c, "status")
+			return errCommandHelp
+		}
+		sort.Strings(blogs)
+
+		var statuses []*entryStatus
+		for _, blog := range blogs {
+			blogConfig := conf.Get(blog)
+			if blogConfig == nil {
+				return fmt.Errorf("blog not found: %s", blog)
+			}
+
+			sts, err := newBroker(blogConfig, c.App.Writer).Status(c.Context)
+			if err != nil {
+				return err
+			}
+			if !c.Bool("json") {
+				printStatus(c.App.Writer, blog, sts)
+				continue
+			}
+			statuses = append(statuses, sts...)
+		}
+		if c.Bool("json") {
+			return printStatusJSON(c.App.Writer, statuses)
+		}
+		return nil
+	},
+}
+
+var commandDiff = &cli.Command{
+	Name:      "diff",
+	Usage:     "Show differences between local entries and remote",
+	ArgsUsage: "<path/to/file>...",
+	Description: "Shows unified diffs of the front matter and the body from remote to local.\n" +
+		"Exits with 0 if there are no differences, 1 if there are, and 2 on errors.",
+	Action: func(c *cli.Context) error {
+		first := c.Args().First()
+		if first == "" {
+			cli.ShowCommandHelp(c, "diff")
+			return errCommandHelp
+		}
+		diffErr := func(err error) error {
+			return &exitStatusError{status: 2, err: err}
+		}
+
+		conf, err := loadConfiguration()
+		if err != nil {
+			return diffErr(err)
+		}
+
+		found := false
+		for _, path := range c.Args().Slice() {
+			absPath, err := filepath.Abs(path)
+			if err != nil {
+				return diffErr(err)
+			}
+			e, err := entryFromFile(absPath)
+			if err != nil {
+				return diffErr(err)
+			}
+			var bc *blogConfig
+			if e.EditURL == "" {
+				bc = conf.detectBlogConfig(absPath)
+			} else {
+				blogID, err := e.blogID()
+				if err != nil {
+					return diffErr(err)
+				}
+				bc = conf.Get(blogID)
+			}
+			if bc == nil {
+				return diffErr(fmt.Errorf("cannot find blog for %s", path))
+			}
+
+			d, err := newBroker(bc, c.App.Writer).DiffEntry(c.Context, absPath, filepath.ToSlash(path))
+			if err != nil {
+				return diffErr(err)
+			}
+			if d != "" {
+				found = true
+				fmt.Fprint(c.App.Writer, d)
+			}
+		}
+		if found {
+			return errDiffFound
+		}
+		return nil
+	},
+}
+
+var commandLint = &cli.Command{
+	Name:      "lint",
+	Usage:     "Check the front matter of local entries",
+	ArgsUsage: "[<path/to/file>...]",
+	Description: "Checks the given entry files, or all entries of the local blogs if no files are given, that is,\n" +
+		"files with EditURL and new files in the entry directory.\n" +
+		"Exits with 1 if any problems are found, including warnings.",
+	Action: func(c *cli.Context) error {
+		conf, err := loadConfiguration()
+		if err != nil {
+			return err
+		}
+
+		cwd, err := os.Getwd()
+		if err != nil {
+			return err
+		}
+		var paths []string
+		if c.NArg() > 0 {
+			paths = c.Args().Slice()
+		} else {
+			blogs := conf.localBlogIDs()
+			if len(blogs) == 0 {
+				cli.ShowCommandHelp(c, "lint")
+				return errCommandHelp
+			}
+			sort.Strings(blogs)
+			for _, blog := range blogs {
+				newBroker(conf.Get(blog), c.App.Writer).walkEntryFiles(func(path, _ string) {
+					if rel, err := filepath.Rel(cwd, path); err == nil && !strings.HasPrefix(rel, "..") {
+						path = rel
+					}
+					paths = append(paths, path)
+				})
+			}
+		}
+
+		problems := 0
+		for _, path := range paths {
+			absPath, err := filepath.Abs(path)
 			if err != nil {
 				return err
 			}
+			ds, err := lintFile(conf, absPath, filepath.ToSlash(path))
+			if err != nil {
+				return err
+			}
+			for _, d := range ds {
+				fmt.Fprintln(c.App.Writer, d)
+			}
+			problems += len(ds)
+		}
+		if problems > 0 {
+			return &exitStatusError{status: 1, err: fmt.Errorf("%d problem(s) found", problems)}
 		}
 		return nil
 	},
 }
+
+var commandReindex = &cli.Command{
+	Name:  "reindex",
+	Usage: "Rebuild the index of local entries",
+	Flags: []cli.Flag{
+		&cli.BoolFlag{Name: "remote", Usage: "record entries which are the same as remote as synced"},
+	},
+	Action: func(c *cli.Context) error {
+		conf, err := loadConfiguration()
+		if err != nil {
+			return err
+		}
+
+		blogs := c.Args().Slice()
+		if len(blogs) == 0 {
+			blogs = conf.localBlogIDs()
+		}
+		if len(blogs) == 0 {
+			cli.ShowCommandHelp(c, "reindex")
+			return errCommandHelp
+		}
+		sort.Strings(blogs)
+
+		for _, blog := range blogs {
+			blogConfig := conf.Get(blog)
+			if blogConfig == nil {
+				return fmt.Errorf("blog not found: %s", blog)
+			}
+
+			b := newBroker(blogConfig, c.App.Writer)
+			var remoteEntries []*entry
+			if c.Bool("remote") {
+				remoteEntries, err = b.FetchRemoteEntries(c.Context, true, true)
+				if err != nil {
+					return err
+				}
+			}
+			s := b.Reindex(remoteEntries)
+			synced := 0
+			for _, es := range s.Entries {
+				if es.Hash != "" {
+					synced++
+				}
+			}
+			fmt.Fprintf(c.App.Writer, "%s: %d entries indexed, %d with sync records\n", blog, len(s.Entries), synced)
+		}
+		return nil
+	},
+}
+
+var commandAuth = &cli.Command{
+	Name:  "auth",
+	Usage: "Manage the authentication of blogs",
+	Subcommands: []*cli.Command{
+		{
+			Name:      "login",
+			Usage:     "Authorize blogsync by OAuth and store the access token",
+			ArgsUsage: "<blogID>",
+			Action: func(c *cli.Context) error {
+				blog := c.Args().First()
+				if blog == "" {
+					cli.ShowCommandHelp(c, "login")
+					return errCommandHelp
+				}
+				if dryRun {
+					return fmt.Errorf("auth login cannot run with --dry-run, which would not store the access token")
+				}
+
+				conf, err := loadConfiguration()
+				if err != nil {
+					return err
+				}
+				blogConfig := conf.Get(blog)
+				if blogConfig == nil {
+					return fmt.Errorf("blog not found: %s", blog)
+				}
+				if blogConfig.authMethod() != authOAuth1 {
+					return fmt.Errorf("auth of %s is not %s but %s", blog, authOAuth1, blogConfig.authMethod())
+				}
+
+				t, err := oauthLogin(c.Context, blogConfig, c.App.Reader, c.App.ErrWriter)
+				if err != nil {
+					return err
+				}
+				p, err := saveStoredToken(blog, t)
+				if err != nil {
+					return err
+				}
+				logf("store", "access token of %s: %s", blog, p)
+				return nil
+			},
+		},
+	},
+}
+
+var commandConfig = &cli.Command{
+	Name:  "config",
+	Usage: "Inspect the configuration",
+	Subcommands: []*cli.Command{
+		{
+			Name:      "show",
+			Usage:     "Show the effective configuration of blogs with the source of each value",
+			ArgsUsage: "[<blogID>...]",
+			Action: func(c *cli.Context) error {
+				conf, err := loadConfiguration()
+				if err != nil {
+					return err
+				}
+				pwd, err := os.Getwd()
+				if err != nil {
+					return err
+				}
+
+				blogs := c.Args().Slice()
+				if len(blogs) == 0 {
+					for blog := range conf.Blogs {
+						blogs = append(blogs, blog)
+					}
+					sort.Strings(blogs)
+				}
+				for _, blog := range blogs {
+					blogConfig := conf.Get(blog)
+					if blogConfig == nil {
+						return fmt.Errorf("blog not found: %s", blog)
+					}
+					if err := showBlogConfig(c.App.Writer, pwd, blogConfig); err != nil {
+						return err
+					}
+				}
+				return nil
+			},
+		},
+		{
+			Name:  "validate",
+			Usage: "Check unknown keys, missing credentials and local roots in the configuration",
+			Action: func(c *cli.Context) error {
+				pwd, err := os.Getwd()
+				if err != nil {
+					return err
+				}
+				var ds []*diagnostic
+				for _, f := range configFiles(pwd) {
+					fds, err := validateConfigFile(f)
+					if err != nil {
+						return err
+					}
+					ds = append(ds, fds...)
+				}
+				conf, err := loadConfiguration()
+				if err == nil {
+					blogs := make([]string, 0, len(conf.Blogs))
+					for blog := range conf.Blogs {
+						blogs = append(blogs, blog)
+					}
+					sort.Strings(blogs)
+					for _, blog := range blogs {
+						ds = append(ds, validateBlogConfig(conf.Get(blog))...)
+					}
+				}
+
+				problems := 0
+				for _, d := range ds {
+					fmt.Fprintln(c.App.Writer, d)
+					if !d.warning {
+						problems++
+					}
+				}
+				if err != nil {
+					return err
+				}
+				if problems > 0 {
+					return &exitStatusError{status: 1, err: fmt.Errorf("%d problem(s) found", problems)}
+				}
+				return nil
+			},
+		},
+	},
+}
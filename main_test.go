@@ -3,36 +3,15 @@
 package main
 
 import (
-	"bytes"
 	"fmt"
 	"net/url"
 	"os"
 	"path/filepath"
-	"regexp"
 	"strings"
 	"testing"
 	"time"
-
-	"github.com/urfave/cli/v2"
 )
 
-func blogsyncApp(app *cli.App) func(...string) (string, error) {
-	buf := &bytes.Buffer{}
-	app.Writer = buf
-	return func(args ...string) (string, error) {
-		buf.Reset()
-		err := app.Run(append([]string{""}, args...))
-		return strings.TrimSpace(buf.String()), err
-	}
-}
-
-func exists(path string) bool {
-	_, err := os.Stat(path)
-	return err == nil
-}
-
-var draftFileReg = regexp.MustCompile(`entry/_draft/\d+\.md$`)
-
 func TestBlogsync(t *testing.T) {
 	blogID := os.Getenv("BLOGSYNC_TEST_BLOG")
 	if blogID == "" {
@@ -106,7 +85,7 @@ func TestBlogsync(t *testing.T) {
 		}
 		defer func() {
 			t.Log("remove the published entry")
-			if _, err := blogsync("remove", entryFile); err != nil {
+			if _, err := blogsync("remove", "--yes", entryFile); err != nil {
 				t.Fatal(err)
 			}
 		}()
@@ -204,7 +183,7 @@ test`), 0644); err != nil {
 		}
 		defer func() {
 			t.Log("remove the published entry")
-			if _, err := blogsync("remove", entryFile); err != nil {
+			if _, err := blogsync("remove", "--yes", entryFile); err != nil {
 				t.Fatal(err)
 			}
 		}()
@@ -257,7 +236,7 @@ test`), 0644); err != nil {
 			t.Fatal(err)
 		}
 		defer func() {
-			if _, err := blogsync("remove", entryFile); err != nil {
+			if _, err := blogsync("remove", "--yes", entryFile); err != nil {
 				t.Fatal(err)
 			}
 		}()
@@ -299,21 +278,10 @@ test`), 0644); err != nil {
 
 		defer func() {
 			t.Log("remove the published entry")
-			if _, err := blogsync("remove", entryFile); err != nil {
+			if _, err := blogsync("remove", "--yes", entryFile); err != nil {
 				t.Fatal(err)
 			}
 		}()
 	})
 
 }
-
-func appendFile(path string, content string) error {
-	fh, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0644)
-	if err != nil {
-		return err
-	}
-	if _, err := fh.WriteString(content); err != nil {
-		return err
-	}
-	return fh.Close()
-}
\ No newline at end of file
@@ -0,0 +1,305 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+var getGit = sync.OnceValue(func() func(...string) (string, error) {
+	g, err := exec.LookPath("git")
+	if err != nil || g == "" {
+		return nil
+	}
+	return func(args ...string) (string, error) {
+		bb, err := exec.Command(g, args...).Output()
+		return string(bb), err
+	}
+})
+
+// gitTimes is a snapshot of the author dates of the files in a git repository,
+// which is taken at once instead of running git for each file.
+type gitTimes struct {
+	mu sync.Mutex
+	// authorDates are the author dates of the last commits of files
+	authorDates map[string]time.Time
+	// modified are the files which have been changed since the last commit
+	modified map[string]bool
+}
+
+// loadGitTimes takes the snapshot of the repository which git runs in with one
+// "git log" walk and one "git status".
+func loadGitTimes(git func(...string) (string, error)) (*gitTimes, error) {
+	top, err := git("rev-parse", "--show-toplevel")
+	if err != nil {
+		return nil, err
+	}
+	top = strings.TrimSpace(top)
+
+	// ref. https://git-scm.com/docs/pretty-formats#Documentation/pretty-formats.txt-emaiem
+	// %aI means "author date, strict ISO 8601 format"
+	out, err := git("-c", "core.quotePath=false", "log", "--format=%x00%aI", "--name-only")
+	if err != nil {
+		return nil, err
+	}
+	gt := &gitTimes{
+		authorDates: map[string]time.Time{},
+		modified:    map[string]bool{},
+	}
+	for _, commit := range strings.Split(out, "\x00") {
+		lines := strings.Split(commit, "\n")
+		t, err := time.Parse(time.RFC3339, strings.TrimSpace(lines[0]))
+		if err != nil {
+			continue
+		}
+		for _, name := range lines[1:] {
+			if name == "" {
+				continue
+			}
+			// The log is ordered from the newest commit
+			p := filepath.Join(top, filepath.FromSlash(name))
+			if _, ok := gt.authorDates[p]; !ok {
+				gt.authorDates[p] = t
+			}
+		}
+	}
+
+	out, err = git("status", "--porcelain", "-z", "--untracked-files=all")
+	if err != nil {
+		return nil, err
+	}
+	records := strings.Split(out, "\x00")
+	for i := 0; i < len(records); i++ {
+		r := records[i]
+		if len(r) < 4 {
+			continue
+		}
+		gt.modified[filepath.Join(top, filepath.FromSlash(r[3:]))] = true
+		if r[0] == 'R' || r[0] == 'C' {
+			// skip the original path of the renamed or copied file
+			i++
+		}
+	}
+	return gt, nil
+}
+
+func gitTimesKey(fpath string) string {
+	p, err := filepath.Abs(fpath)
+	if err != nil {
+		return fpath
+	}
+	if rp, err := filepath.EvalSymlinks(p); err == nil {
+		p = rp
+	}
+	return p
+}
+
+// authorDate returns the author date of the last commit of the file at fpath. ok
+// is false if the file is not committed or has been modified.
+func (gt *gitTimes) authorDate(fpath string) (t time.Time, ok bool) {
+	p := gitTimesKey(fpath)
+	gt.mu.Lock()
+	defer gt.mu.Unlock()
+	if gt.modified[p] {
+		return time.Time{}, false
+	}
+	t, ok = gt.authorDates[p]
+	return t, ok
+}
+
+// markModified marks the file at fpath as modified after the snapshot was taken.
+func (gt *gitTimes) markModified(fpath string) {
+	p := gitTimesKey(fpath)
+	gt.mu.Lock()
+	defer gt.mu.Unlock()
+	gt.modified[p] = true
+}
+
+// loadGitCLITimes takes the snapshot of the repository at root with the git
+// command. The shallow repository is unshallowed first if unshallow is true,
+// otherwise files last changed before the shallow boundary get its date.
+func loadGitCLITimes(root string, unshallow bool) (*gitTimes, error) {
+	g := getGit()
+	if g == nil {
+		return nil, fmt.Errorf("git command is not found")
+	}
+	git := func(args ...string) (string, error) {
+		return g(append([]string{"-C", root}, args...)...)
+	}
+	if unshallow {
+		if out, err := git("rev-parse", "--is-shallow-repository"); err == nil && strings.TrimSpace(out) == "true" {
+			if _, err := git("fetch", "--unshallow"); err != nil {
+				logf("warn", "failed to unshallow %s: %s", root, err)
+			}
+		}
+	}
+	return loadGitTimes(git)
+}
+
+const (
+	timestampSourceMtime = "mtime"
+	timestampSourceGit   = "git"
+	timestampSourceGoGit = "go-git"
+)
+
+// timestampSource gives the last modified times of local entry files, which are
+// compared with the remote entries when no sync record is available.
+type timestampSource interface {
+	// modTime returns the last modified time of the file at fpath. ok is false
+	// if the source knows nothing about the file, and then its mtime is used.
+	modTime(fpath string) (t time.Time, ok bool)
+	// fileModified is called when blogsync has written the file at fpath.
+	fileModified(fpath string)
+}
+
+// mtimeSource uses the mtime of files in the file system as is.
+type mtimeSource struct{}
+
+func (mtimeSource) modTime(string) (time.Time, bool) { return time.Time{}, false }
+func (mtimeSource) fileModified(string)              {}
+
+// gitSource uses the author date of the last commit of files, which is stable
+// across clones unlike mtime. Files which are not committed or have been
+// modified fall back to mtime. Each repository is read once with load.
+type gitSource struct {
+	load func(root string) (*gitTimes, error)
+
+	mu sync.Mutex
+	// repos are the snapshots keyed by the root directory. It is nil for the
+	// repository which failed to be loaded.
+	repos map[string]*gitTimes
+	// roots are the repository roots keyed by directory, "" if not in a repository
+	roots map[string]string
+}
+
+func newGitSource(load func(root string) (*gitTimes, error)) *gitSource {
+	return &gitSource{
+		load:  load,
+		repos: map[string]*gitTimes{},
+		roots: map[string]string{},
+	}
+}
+
+// repoRoot returns the nearest ancestor directory of dir which has .git.
+func (gs *gitSource) repoRoot(dir string) string {
+	if root, ok := gs.roots[dir]; ok {
+		return root
+	}
+	var root string
+	if _, err := os.Stat(filepath.Join(dir, ".git")); err == nil {
+		root = dir
+	} else if parent := filepath.Dir(dir); parent != dir {
+		root = gs.repoRoot(parent)
+	}
+	gs.roots[dir] = root
+	return root
+}
+
+func (gs *gitSource) times(fpath string) *gitTimes {
+	gs.mu.Lock()
+	defer gs.mu.Unlock()
+	root := gs.repoRoot(filepath.Dir(gitTimesKey(fpath)))
+	if root == "" {
+		return nil
+	}
+	gt, ok := gs.repos[root]
+	if !ok {
+		var err error
+		if gt, err = gs.load(root); err != nil {
+			logf("warn", "failed to read git repository %s, mtime is used instead: %s", root, err)
+		}
+		gs.repos[root] = gt
+	}
+	return gt
+}
+
+func (gs *gitSource) modTime(fpath string) (time.Time, bool) {
+	if gt := gs.times(fpath); gt != nil {
+		return gt.authorDate(fpath)
+	}
+	return time.Time{}, false
+}
+
+func (gs *gitSource) fileModified(fpath string) {
+	if gt := gs.times(fpath); gt != nil {
+		gt.markModified(fpath)
+	}
+}
+
+func newTimestampSource(name string, unshallow bool) (timestampSource, error) {
+	switch name {
+	case "", timestampSourceGit:
+		if getGit() == nil {
+			return mtimeSource{}, nil
+		}
+		return newGitSource(func(root string) (*gitTimes, error) {
+			return loadGitCLITimes(root, unshallow)
+		}), nil
+	case timestampSourceGoGit:
+		return newGitSource(loadGoGitTimes), nil
+	case timestampSourceMtime:
+		return mtimeSource{}, nil
+	}
+	return nil, fmt.Errorf("unknown timestamp_source %q, must be one of %q, %q or %q",
+		name, timestampSourceGit, timestampSourceGoGit, timestampSourceMtime)
+}
+
+// timestamps is the timestamp source used in the process, which is configured
+// by setTimestampSource.
+var timestamps = struct {
+	sync.Mutex
+	src timestampSource
+	// key identifies the configuration of src
+	key string
+}{}
+
+// setTimestampSource switches the timestamp source according to the timestamp_source
+// and git_unshallow configuration. The current source is kept if the configuration
+// is not changed, so that the repositories are not read again.
+func setTimestampSource(bc *blogConfig) error {
+	timestamps.Lock()
+	defer timestamps.Unlock()
+	return useTimestampSource(bc.TimestampSource, bc.GitUnshallow != nil && *bc.GitUnshallow)
+}
+
+func useTimestampSource(name string, unshallow bool) error {
+	key := fmt.Sprintf("%s:%t", name, unshallow)
+	if timestamps.src != nil && timestamps.key == key {
+		return nil
+	}
+	src, err := newTimestampSource(name, unshallow)
+	if err != nil {
+		return err
+	}
+	timestamps.src, timestamps.key = src, key
+	return nil
+}
+
+func getTimestampSource() timestampSource {
+	timestamps.Lock()
+	defer timestamps.Unlock()
+	if timestamps.src == nil {
+		useTimestampSource("", false)
+	}
+	return timestamps.src
+}
+
+// fileModified tells the timestamp source that the file at fpath has been written.
+func fileModified(fpath string) {
+	getTimestampSource().fileModified(fpath)
+}
+
+func modTime(fpath string) (time.Time, error) {
+	fi, err := os.Stat(fpath)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if t, ok := getTimestampSource().modTime(fpath); ok {
+		return t, nil
+	}
+	return fi.ModTime(), nil
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestGitTimes(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not available")
+	}
+	dir, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	git := func(args ...string) (string, error) {
+		cmd := exec.Command("git", append([]string{"-C", dir}, args...)...)
+		cmd.Env = append(os.Environ(),
+			"GIT_AUTHOR_NAME=blogsync", "GIT_AUTHOR_EMAIL=blogsync@example.com",
+			"GIT_COMMITTER_NAME=blogsync", "GIT_COMMITTER_EMAIL=blogsync@example.com",
+			"GIT_CONFIG_GLOBAL=/dev/null", "GIT_CONFIG_NOSYSTEM=1")
+		bb, err := cmd.Output()
+		return string(bb), err
+	}
+	write := func(name, content string) {
+		t.Helper()
+		p := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	commit := func(date string) {
+		t.Helper()
+		if _, err := git("add", "-A"); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := git("commit", "-q", "-m", "commit", "--date", date); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if _, err := git("init", "-q"); err != nil {
+		t.Fatal(err)
+	}
+	write("entry/a.md", "a")
+	write("entry/b.md", "b")
+	commit("2020-01-02T03:04:05+09:00")
+	write("entry/b.md", "b2")
+	write("entry/日本語.md", "ja")
+	commit("2021-02-03T04:05:06+09:00")
+	write("entry/a.md", "a2")
+	write("entry/c.md", "c")
+
+	d2021 := time.Date(2021, 2, 3, 4, 5, 6, 0, jst)
+	testCases := []struct {
+		name   string
+		expect time.Time
+		ok     bool
+	}{
+		{name: "entry/a.md", ok: false},
+		{name: "entry/b.md", expect: d2021, ok: true},
+		{name: "entry/日本語.md", expect: d2021, ok: true},
+		{name: "entry/c.md", ok: false},
+	}
+	loaders := map[string]func(root string) (*gitTimes, error){
+		timestampSourceGit: func(root string) (*gitTimes, error) {
+			return loadGitCLITimes(root, false)
+		},
+		timestampSourceGoGit: loadGoGitTimes,
+	}
+	for name, load := range loaders {
+		t.Run(name, func(t *testing.T) {
+			src := newGitSource(load)
+			for _, tc := range testCases {
+				got, ok := src.modTime(filepath.Join(dir, filepath.FromSlash(tc.name)))
+				if ok != tc.ok || !got.Equal(tc.expect) {
+					t.Errorf("%s: got (%s, %t), want (%s, %t)", tc.name, got, ok, tc.expect, tc.ok)
+				}
+			}
+
+			bFile := filepath.Join(dir, "entry", "b.md")
+			src.fileModified(bFile)
+			if _, ok := src.modTime(bFile); ok {
+				t.Errorf("file written by blogsync should not have the author date")
+			}
+
+			outside := filepath.Join(t.TempDir(), "outside.md")
+			if err := os.WriteFile(outside, nil, 0644); err != nil {
+				t.Fatal(err)
+			}
+			if _, ok := src.modTime(outside); ok {
+				t.Errorf("file outside of the repository should not have the author date")
+			}
+		})
+	}
+}
+
+func TestNewTimestampSource(t *testing.T) {
+	if src, err := newTimestampSource(timestampSourceMtime, false); err != nil || src != (mtimeSource{}) {
+		t.Errorf("unexpected source: %#v, %v", src, err)
+	}
+	if src, err := newTimestampSource(timestampSourceGoGit, false); err != nil {
+		t.Errorf("error should be nil but: %s", err)
+	} else if _, ok := src.(*gitSource); !ok {
+		t.Errorf("unexpected source: %#v", src)
+	}
+	if _, err := newTimestampSource("svn", false); err == nil {
+		t.Errorf("error should be occurred for unknown source")
+	}
+}
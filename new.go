@@ -0,0 +1,75 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// newEntryData is the data passed to the template of new entries.
+type newEntryData struct {
+	*entry
+	BlogID string
+	// Slug is the path of the entry under the entry directory without the
+	// extension, which is empty for drafts in _draft/.
+	Slug string
+}
+
+// newEntryPath returns the path of the file for a new entry with slug. The
+// entry goes into _draft/ with the name from now if slug is empty.
+func newEntryPath(bc *blogConfig, slug string, now time.Time) (string, error) {
+	if slug == "" {
+		slug = draftDir + now.Format("20060102150405")
+	} else {
+		slug = strings.Trim(filepath.ToSlash(slug), "/")
+		if customPathReg.MatchString(slug) || hasDotSegment(slug) {
+			return "", fmt.Errorf("invalid slug: %q", slug)
+		}
+	}
+	ext := entryFileExt(slug)
+	if ext == "" {
+		ext = entryExt
+	}
+	return filepath.Join(bc.localRoot(), filepath.FromSlash(bc.entryDirectory()+trimEntryExt(slug)+ext)), nil
+}
+
+// renderNewEntry returns the content of the file for a new entry with the
+// template configured by entry_template, or the front matter of e followed by
+// an empty body if it is not configured.
+func renderNewEntry(bc *blogConfig, e *entry, slug string) (string, error) {
+	if bc.EntryTemplate == "" {
+		return e.fullContent(), nil
+	}
+	bb, err := os.ReadFile(bc.EntryTemplate)
+	if err != nil {
+		return "", err
+	}
+	tmpl, err := template.New(filepath.Base(bc.EntryTemplate)).Parse(string(bb))
+	if err != nil {
+		return "", err
+	}
+	buf := &strings.Builder{}
+	if err := tmpl.Execute(buf, &newEntryData{entry: e, BlogID: bc.BlogID, Slug: slug}); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// openEditor opens path with $VISUAL or $EDITOR.
+func openEditor(path string) error {
+	editor := os.Getenv("VISUAL")
+	if editor == "" {
+		editor = os.Getenv("EDITOR")
+	}
+	args := strings.Fields(editor)
+	if len(args) == 0 {
+		return fmt.Errorf("$EDITOR is not set")
+	}
+	cmd := exec.Command(args[0], append(args[1:], path)...)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	return cmd.Run()
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNewEntryPath(t *testing.T) {
+	now := time.Date(2024, 1, 2, 3, 4, 5, 0, jst)
+	bc := &blogConfig{BlogID: "example.com", LocalRoot: "/blog"}
+	testCases := []struct {
+		slug, expect string
+		expectErr    bool
+	}{
+		{slug: "hello", expect: "/blog/example.com/entry/hello.md"},
+		{slug: "2024/01/hello.hatena", expect: "/blog/example.com/entry/2024/01/hello.hatena"},
+		{slug: "", expect: "/blog/example.com/entry/_draft/20240102030405.md"},
+		{slug: "../hello", expectErr: true},
+		{slug: "hello world", expectErr: true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.slug, func(t *testing.T) {
+			got, err := newEntryPath(bc, tc.slug, now)
+			if tc.expectErr {
+				if err == nil {
+					t.Errorf("error should be occurred but got %s", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != filepath.FromSlash(tc.expect) {
+				t.Errorf("got %s, want %s", got, tc.expect)
+			}
+		})
+	}
+}
+
+func TestNewCommand(t *testing.T) {
+	s, dir := setupFakeBlog(t)
+	blogsync := blogsyncApp(newApp())
+
+	t.Run("default", func(t *testing.T) {
+		out, err := blogsync("new", "--title", "Hello", "--category", "blog", fakeBlogID, "hello")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if out != filepath.Join(dir, "entry", "hello.md") {
+			t.Errorf("unexpected path: %s", out)
+		}
+		e, err := entryFromFile(out)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if e.Title != "Hello" || len(e.Category) != 1 || e.Category[0] != "blog" || e.Date == nil || e.IsDraft {
+			t.Errorf("unexpected entry: %+v", e.entryHeader)
+		}
+		if _, err := blogsync("new", fakeBlogID, "hello"); err == nil {
+			t.Errorf("existing file should not be overwritten")
+		}
+	})
+
+	t.Run("template", func(t *testing.T) {
+		tmpl := `---
+Title: {{.Title}}
+Date: {{.Date.Format "2006-01-02T15:04:05-07:00"}}
+Series: {{.BlogID}}
+{{- if .IsDraft}}
+Draft: true
+{{- end}}
+---
+
+# {{.Slug}}
+`
+		// A relative path is resolved against the directory of the configuration file
+		confDir := filepath.Join(dir, ".config", "blogsync")
+		if err := os.MkdirAll(confDir, 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(confDir, "entry.tmpl"), []byte(tmpl), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(confDir, "config.yaml"),
+			[]byte(fakeBlogID+":\n  entry_template: entry.tmpl\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		out, err := blogsync("new", "--title", "Templated", fakeBlogID, "2024/templated")
+		if err != nil {
+			t.Fatal(err)
+		}
+		e, err := entryFromFile(out)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if e.Title != "Templated" || e.ExtraMap()["Series"] != fakeBlogID || e.Content != "# 2024/templated\n" {
+			t.Errorf("unexpected entry: %+v, %q", e.entryHeader, e.Content)
+		}
+	})
+
+	t.Run("draft", func(t *testing.T) {
+		out, err := blogsync("new", "--title", "Draft", fakeBlogID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.HasPrefix(out, filepath.Join(dir, "entry", "_draft")+string(filepath.Separator)) {
+			t.Fatalf("draft should be created in _draft/: %s", out)
+		}
+		draftFile := out
+		if _, err := blogsync("push", draftFile); err != nil {
+			t.Fatal(err)
+		}
+		if exists(draftFile) {
+			t.Errorf("the scaffolded file should be replaced by _draft/{entryID}")
+		}
+		entries := s.Entries()
+		if len(entries) != 1 || entries[0].CustomURL != "" || entries[0].Control == nil || entries[0].Control.Draft != "yes" {
+			t.Fatalf("unexpected remote entries: %+v", entries)
+		}
+		id := filepath.Base(entries[0].Links.Find("edit").Href)
+		if !exists(filepath.Join(dir, "entry", "_draft", id+".md")) {
+			t.Errorf("draft should be stored as _draft/%s.md", id)
+		}
+	})
+}
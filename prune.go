@@ -0,0 +1,114 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+)
+
+const (
+	pruneDelete  = "delete"
+	pruneArchive = "archive"
+)
+
+// pruneMode is the value of --prune flag. It can be given without a value like
+// a boolean flag, which means "delete".
+type pruneMode string
+
+func (p *pruneMode) Set(v string) error {
+	switch v {
+	case "true", pruneDelete:
+		*p = pruneDelete
+	case pruneArchive:
+		*p = pruneArchive
+	case "false", "":
+		*p = ""
+	default:
+		return fmt.Errorf("invalid prune mode %q, must be %q or %q", v, pruneDelete, pruneArchive)
+	}
+	return nil
+}
+
+func (p *pruneMode) String() string {
+	return string(*p)
+}
+
+func (p *pruneMode) IsBoolFlag() bool {
+	return true
+}
+
+// deletedEntries returns the local files of entries which have been deleted on
+// remote, keyed by EditURL. remoteEntries must be the full listing of the blog.
+func (b *broker) deletedEntries(localEntryMap map[string]string, remoteEntries []*entry) map[string]string {
+	seen := map[string]bool{}
+	for _, re := range remoteEntries {
+		seen[re.EditURL] = true
+	}
+	deleted := map[string]string{}
+	for editURL, path := range localEntryMap {
+		if seen[editURL] {
+			continue
+		}
+		eh := &entryHeader{EditURL: editURL}
+		if blogID, err := eh.blogID(); err != nil || blogID != b.BlogID {
+			continue
+		}
+		// The listing does not contain static pages when they are not available
+		if eh.isStaticPage() && b.staticPageUnavailable {
+			continue
+		}
+		deleted[editURL] = path
+	}
+	return deleted
+}
+
+// archivePath returns the path in the archive directory for the entry file at path.
+func (b *broker) archivePath(path string) string {
+	return filepath.Join(b.localRoot(), stateDir, "archive", filepath.FromSlash(b.relPath(path)))
+}
+
+// PruneEntries handles the local files of entries deleted on remote. They are
+// deleted or moved into the archive directory according to mode, or only
+// reported if mode is empty.
+func (b *broker) PruneEntries(localEntryMap map[string]string, remoteEntries []*entry, mode string) error {
+	deleted := b.deletedEntries(localEntryMap, remoteEntries)
+	editURLs := make([]string, 0, len(deleted))
+	for editURL := range deleted {
+		editURLs = append(editURLs, editURL)
+	}
+	sort.Slice(editURLs, func(i, j int) bool { return deleted[editURLs[i]] < deleted[editURLs[j]] })
+
+	for _, editURL := range editURLs {
+		path := deleted[editURL]
+		switch mode {
+		case pruneDelete:
+			if !dryRun {
+				b.logf("prune", "delete %s", path)
+			}
+			if err := b.removeFile(path); err != nil {
+				return err
+			}
+			if err := b.removeFormattedHTML(path); err != nil {
+				return err
+			}
+		case pruneArchive:
+			dest := b.archivePath(path)
+			if !dryRun {
+				b.logf("prune", "archive %s -> %s", path, dest)
+			}
+			if err := b.moveFile(path, dest); err != nil {
+				return err
+			}
+			if err := b.removeFormattedHTML(path); err != nil {
+				return err
+			}
+		default:
+			b.logf("warn", "deleted on remote: %s", path)
+			continue
+		}
+		if !dryRun {
+			b.syncState().delete(editURL)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,54 @@
+package main
+
+// pullEntry stores the remote entry re into the local file. oldPath is the path
+// of the existing local file of the entry, which may differ from the path for re
+// when the entry has been renamed on either side.
+func (b *broker) pullEntry(re *entry, oldPath string) error {
+	path := b.LocalPath(re)
+	if oldPath == "" || oldPath == path {
+		if _, err := b.StoreFresh(re, path); err != nil {
+			return err
+		}
+		return b.storeHTML(re, path)
+	}
+
+	// Entry has been renamed locally
+	if localChanged, remoteChanged, ok := b.changedSinceSync(oldPath, re); ok {
+		if !remoteChanged {
+			return nil
+		}
+		if localChanged {
+			return b.writeConflict(oldPath, re)
+		}
+	} else {
+		localMtime, _ := modTime(oldPath)
+		if localMtime.After(*re.LastModified) {
+			b.logf("warn", "local file is newer, skipping remote: %s", oldPath)
+			return nil
+		}
+	}
+	if !dryRun {
+		b.logf("store", "renamed: %s -> %s", oldPath, path)
+	}
+	if re.Extra == nil {
+		re.Extra = localExtraHeaders(oldPath)
+	}
+	if _, err := b.StoreFresh(re, path); err != nil {
+		return err
+	}
+	if err := b.removeFile(oldPath); err != nil {
+		return err
+	}
+	if err := b.removeFormattedHTML(oldPath); err != nil {
+		return err
+	}
+	return b.storeHTML(re, path)
+}
+
+// storeHTML stores the rendered HTML of re if pull --with-html is given.
+func (b *broker) storeHTML(re *entry, path string) error {
+	if !b.withHTML {
+		return nil
+	}
+	return b.storeFormattedHTML(re, path)
+}
@@ -0,0 +1,240 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/x-motemen/blogsync/atom"
+)
+
+func TestIncrementalPull(t *testing.T) {
+	s, dir := setupFakeBlog(t)
+	s.PerPage = 1
+
+	var editURLs []string
+	for i, name := range []string{"entry1", "entry2", "entry3"} {
+		d := time.Date(2020+i, 1, 2, 3, 4, 5, 0, jst)
+		e, err := s.AddEntry(&atom.Entry{
+			Title:     name,
+			Content:   atom.Content{Content: name + "\n"},
+			Updated:   &d,
+			Edited:    &d,
+			CustomURL: name,
+		}, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		editURLs = append(editURLs, e.Links.Find("edit").Href)
+	}
+
+	blogsync := blogsyncApp(newApp())
+	if _, err := blogsync("pull"); err != nil {
+		t.Fatal(err)
+	}
+	st, err := loadSyncState(dir, fakeBlogID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expect := time.Date(2022, 1, 2, 3, 4, 5, 0, jst); !st.LastPull.Equal(expect) {
+		t.Errorf("LastPull: got %s, want %s", st.LastPull, expect)
+	}
+
+	entry1File := filepath.Join(dir, "entry", "entry1.md")
+	if err := os.Remove(entry1File); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Log("Old entries are not fetched by incremental pull")
+	if _, err := blogsync("pull"); err != nil {
+		t.Fatal(err)
+	}
+	if exists(entry1File) {
+		t.Errorf("%s should not be fetched", entry1File)
+	}
+
+	t.Log("Entries edited since the last pull are fetched")
+	s.UpdateEntry(editURLs[0], &atom.Entry{
+		Title:   "entry1",
+		Content: atom.Content{Content: "entry1 updated\n"},
+	})
+	if _, err := blogsync("pull"); err != nil {
+		t.Fatal(err)
+	}
+	if !exists(entry1File) {
+		t.Errorf("%s should be fetched", entry1File)
+	}
+
+	t.Log("All entries are fetched with --full")
+	entry2File := filepath.Join(dir, "entry", "entry2.md")
+	if err := os.Remove(entry2File); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := blogsync("pull", "--full"); err != nil {
+		t.Fatal(err)
+	}
+	if !exists(entry2File) {
+		t.Errorf("%s should be fetched", entry2File)
+	}
+}
+
+func TestPullPrune(t *testing.T) {
+	s, dir := setupFakeBlog(t)
+
+	var editURLs []string
+	for i, name := range []string{"entry1", "entry2", "entry3"} {
+		d := time.Date(2020+i, 1, 2, 3, 4, 5, 0, jst)
+		e, err := s.AddEntry(&atom.Entry{
+			Title:     name,
+			Content:   atom.Content{Content: name + "\n"},
+			Updated:   &d,
+			Edited:    &d,
+			CustomURL: name,
+		}, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		editURLs = append(editURLs, e.Links.Find("edit").Href)
+	}
+
+	blogsync := blogsyncApp(newApp())
+	if _, err := blogsync("pull"); err != nil {
+		t.Fatal(err)
+	}
+	s.Delete(editURLs[0])
+	entry1File := filepath.Join(dir, "entry", "entry1.md")
+	entry2File := filepath.Join(dir, "entry", "entry2.md")
+
+	t.Log("Deleted entries are only reported without --prune")
+	if _, err := blogsync("pull", "--full"); err != nil {
+		t.Fatal(err)
+	}
+	if !exists(entry1File) {
+		t.Errorf("local files should be kept")
+	}
+
+	t.Log("Deleted entries are archived with --prune=archive")
+	if _, err := blogsync("pull", "--prune=archive"); err != nil {
+		t.Fatal(err)
+	}
+	if exists(entry1File) {
+		t.Errorf("%s should be archived", entry1File)
+	}
+	if !exists(filepath.Join(dir, stateDir, "archive", "entry", "entry1.md")) {
+		t.Errorf("%s is not found in the archive directory", entry1File)
+	}
+
+	t.Log("Deleted entries are deleted with --prune")
+	s.Delete(editURLs[1])
+	if _, err := blogsync("pull", "--prune"); err != nil {
+		t.Fatal(err)
+	}
+	if exists(entry2File) {
+		t.Errorf("%s should be deleted", entry2File)
+	}
+	if !exists(filepath.Join(dir, "entry", "entry3.md")) {
+		t.Errorf("existing entries should be kept")
+	}
+}
+
+func TestPullJobs(t *testing.T) {
+	s, dir := setupFakeBlog(t)
+	s.PerPage = 5
+
+	var expect []string
+	for i := 0; i < 20; i++ {
+		d := time.Date(2020, 1, 2, 3, 4, i, 0, jst)
+		name := fmt.Sprintf("entry%02d", i)
+		if _, err := s.AddEntry(&atom.Entry{
+			Title:     name,
+			Content:   atom.Content{Content: name + "\n"},
+			Updated:   &d,
+			Edited:    &d,
+			CustomURL: name,
+		}, false); err != nil {
+			t.Fatal(err)
+		}
+		// entries are listed in the order of app:edited descending
+		expect = append([]string{filepath.Join(dir, "entry", name+".md")}, expect...)
+	}
+
+	blogsync := blogsyncApp(newApp())
+	out, err := blogsync("pull", "--jobs", "4")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if g, e := strings.Split(out, "\n"), expect; !reflect.DeepEqual(g, e) {
+		t.Errorf("output should be in the order of entries:\n got: %v\nwant: %v", g, e)
+	}
+	for _, f := range expect {
+		if !exists(f) {
+			t.Errorf("%s should be pulled", f)
+		}
+	}
+	st, err := loadSyncState(dir, fakeBlogID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(st.Entries) != len(expect) {
+		t.Errorf("all entries should be recorded: %d", len(st.Entries))
+	}
+}
+
+func TestPullWithHTML(t *testing.T) {
+	s, dir := setupFakeBlog(t)
+	editURL := addFakeAtomEntry(t, s, &atom.Entry{
+		Title:     "html",
+		Content:   atom.Content{Content: "a < b\n"},
+		CustomURL: "html",
+	})
+	entryFile := filepath.Join(dir, "entry", "html.md")
+	htmlFile := filepath.Join(dir, "entry", "html.formatted.html")
+
+	blogsync := blogsyncApp(newApp())
+	if _, err := blogsync("pull"); err != nil {
+		t.Fatal(err)
+	}
+	if exists(htmlFile) {
+		t.Errorf("%s should not be stored without --with-html", htmlFile)
+	}
+
+	t.Log("HTML is stored for existing entries with --with-html")
+	if _, err := blogsync("pull", "--with-html", "--full"); err != nil {
+		t.Fatal(err)
+	}
+	bb, err := os.ReadFile(htmlFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expect := "<p>a &lt; b</p>\n"; string(bb) != expect {
+		t.Errorf("got %q, want %q", string(bb), expect)
+	}
+	out, err := blogsync("status")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out, "nothing to sync") {
+		t.Errorf("HTML files should not be regarded as entries: %s", out)
+	}
+
+	t.Log("HTML cannot be pushed as an entry")
+	if _, err := blogsync("push", htmlFile); err == nil || !strings.Contains(err.Error(), "cannot be pushed") {
+		t.Errorf("push of the HTML should be refused but: %v", err)
+	}
+	if n := len(s.Entries()); n != 1 {
+		t.Errorf("nothing should be posted: %d entries", n)
+	}
+
+	t.Log("HTML is removed with the entry")
+	s.Delete(editURL)
+	if _, err := blogsync("pull", "--prune"); err != nil {
+		t.Fatal(err)
+	}
+	if exists(entryFile) || exists(htmlFile) {
+		t.Errorf("both %s and %s should be deleted", entryFile, htmlFile)
+	}
+}
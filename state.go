@@ -0,0 +1,350 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+const stateDir = ".blogsync"
+
+// entryState is the record of an entry in the local root and the remote revision
+// of it which was synced last.
+type entryState struct {
+	// Path is the slash separated path of the entry file relative to the local root
+	Path string `json:"path"`
+	// Edited is app:edited of the remote entry
+	Edited time.Time `json:"edited,omitzero"`
+	// Hash is the hash of the file content written for the remote entry. It is
+	// empty if the entry is indexed but has never been synced.
+	Hash string `json:"hash,omitempty"`
+	// SyncedAt is the time when the entry was synced last
+	SyncedAt time.Time `json:"synced_at,omitzero"`
+}
+
+// imageState is the record of an image uploaded to Hatena Fotolife or downloaded
+// by pull --assets.
+type imageState struct {
+	// Syntax is the Hatena syntax of the image such as "f:id:motemen:20140101123456p:image".
+	// It is empty for the images downloaded from other than Fotolife.
+	Syntax string `json:"syntax,omitempty"`
+	// URL is the URL of the remote image
+	URL string `json:"url"`
+	// Path is the slash separated path of the downloaded image relative to the local root
+	Path string `json:"path,omitempty"`
+}
+
+// syncState is the index of entries under a local root with the record of the
+// last sync. It is stored in .blogsync/state.json under the local root.
+type syncState struct {
+	BlogID string `json:"blog_id"`
+	// Indexed is true when Entries covers all entry files in the local root
+	Indexed bool `json:"indexed"`
+	// IndexedAt is when Entries was last known to cover all entry files. The
+	// index is stale if any directory in the local root is modified after it.
+	IndexedAt time.Time `json:"indexed_at,omitzero"`
+	// LastPull is the latest app:edited of the entries at the last successful
+	// pull of both published entries and drafts
+	LastPull time.Time              `json:"last_pull,omitzero"`
+	Entries  map[string]*entryState `json:"entries"` // keyed by EditURL
+	// Images is the mapping of the local images to the remote ones, which are
+	// uploaded to Hatena Fotolife or downloaded by pull --assets, keyed by the
+	// hash of their content
+	Images map[string]*imageState `json:"images,omitempty"`
+
+	path  string
+	dirty bool
+	// fresh is true when the index is checked or rebuilt in the process and
+	// kept up to date since then
+	fresh bool
+	mu    sync.Mutex
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+func contentHash(b []byte) string {
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+func newSyncState(root, blogID string) *syncState {
+	return &syncState{
+		BlogID:  blogID,
+		Entries: map[string]*entryState{},
+		path:    filepath.Join(root, stateDir, "state.json"),
+	}
+}
+
+func loadSyncState(root, blogID string) (*syncState, error) {
+	s := newSyncState(root, blogID)
+	bb, err := os.ReadFile(s.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return s, nil
+		}
+		return nil, err
+	}
+	if err := json.Unmarshal(bb, s); err != nil {
+		return nil, err
+	}
+	if s.Entries == nil {
+		s.Entries = map[string]*entryState{}
+	}
+	return s, nil
+}
+
+func (s *syncState) get(editURL string) *entryState {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.Entries[editURL]
+}
+
+func (s *syncState) set(editURL string, es *entryState) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.Entries[editURL] = es
+	s.dirty = true
+}
+
+func (s *syncState) delete(editURL string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.Entries[editURL]; ok {
+		delete(s.Entries, editURL)
+		s.dirty = true
+	}
+}
+
+func (s *syncState) image(hash string) *imageState {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.Images[hash]
+}
+
+func (s *syncState) setImage(hash string, is *imageState) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.Images == nil {
+		s.Images = map[string]*imageState{}
+	}
+	s.Images[hash] = is
+	s.dirty = true
+}
+
+// updateLastPull advances LastPull to the latest app:edited of entries.
+func (s *syncState) updateLastPull(entries []*entry) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, e := range entries {
+		if e.LastModified != nil && e.LastModified.After(s.LastPull) {
+			s.LastPull = *e.LastModified
+			s.dirty = true
+		}
+	}
+}
+
+func (s *syncState) save() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.dirty {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0755); err != nil {
+		return err
+	}
+	if s.fresh {
+		s.IndexedAt = time.Now()
+	}
+	bb, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, append(bb, '\n'), 0644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, s.path); err != nil {
+		return err
+	}
+	s.dirty = false
+	return nil
+}
+
+// syncStates holds the states loaded in the process keyed by local root, so that
+// brokers for the same blog share one state. They are saved by saveSyncStates
+// after each command.
+var syncStates = struct {
+	sync.Mutex
+	m map[string]*syncState
+}{m: map[string]*syncState{}}
+
+func (b *broker) syncState() *syncState {
+	root := b.localRoot()
+	syncStates.Lock()
+	defer syncStates.Unlock()
+	if s, ok := syncStates.m[root]; ok {
+		return s
+	}
+	s, err := loadSyncState(root, b.BlogID)
+	if err != nil {
+		logf("warn", "failed to load sync state, ignoring it: %s", err)
+		s = newSyncState(root, b.BlogID)
+	}
+	syncStates.m[root] = s
+	return s
+}
+
+func saveSyncStates() error {
+	syncStates.Lock()
+	defer syncStates.Unlock()
+	for root, s := range syncStates.m {
+		// The states changed in the dry run mode are discarded
+		if !dryRun {
+			if err := s.save(); err != nil {
+				return err
+			}
+		}
+		delete(syncStates.m, root)
+	}
+	return nil
+}
+
+// relPath returns the slash separated path relative to the local root.
+func (b *broker) relPath(path string) string {
+	rel, err := filepath.Rel(b.localRoot(), path)
+	if err != nil {
+		return filepath.ToSlash(path)
+	}
+	return filepath.ToSlash(rel)
+}
+
+// absPath is the reverse of relPath.
+func (b *broker) absPath(rel string) string {
+	if filepath.IsAbs(rel) {
+		return filepath.FromSlash(rel)
+	}
+	return filepath.Join(b.localRoot(), filepath.FromSlash(rel))
+}
+
+// recordSync records that the file at path has been synced with the remote entry e
+// and its content is content.
+func (b *broker) recordSync(e *entry, path string, content []byte) {
+	if e.EditURL == "" || e.LastModified == nil {
+		return
+	}
+	b.syncState().set(e.EditURL, &entryState{
+		Path:     b.relPath(path),
+		Edited:   *e.LastModified,
+		Hash:     contentHash(content),
+		SyncedAt: time.Now(),
+	})
+}
+
+// indexedEntryMap returns the map of EditURL to file path from the index. ok is
+// false if the index is empty or stale, that is, some of the files are missing
+// or some directories are modified after the index, where files may be added.
+func (b *broker) indexedEntryMap() (m map[string]string, ok bool) {
+	s := b.syncState()
+	if !s.Indexed {
+		return nil, false
+	}
+	m = map[string]string{}
+	for editURL, es := range s.Entries {
+		p := b.absPath(es.Path)
+		if !fileExists(p) {
+			return nil, false
+		}
+		m[editURL] = p
+	}
+	if dirModifiedAfter(b.localRoot(), s.IndexedAt) {
+		return nil, false
+	}
+	s.mu.Lock()
+	s.fresh = true
+	s.mu.Unlock()
+	return m, true
+}
+
+// dirModifiedAfter reports whether root or any directory under it except the
+// state directory is modified after t.
+func dirModifiedAfter(root string, t time.Time) bool {
+	modified := false
+	filepath.WalkDir(root, func(path string, d os.DirEntry, err error) error {
+		if err != nil || !d.IsDir() {
+			return nil
+		}
+		if d.Name() == stateDir {
+			return filepath.SkipDir
+		}
+		if fi, err := d.Info(); err == nil && fi.ModTime().After(t) {
+			modified = true
+			return filepath.SkipAll
+		}
+		return nil
+	})
+	return modified
+}
+
+// Reindex rebuilds the index by walking the local root. The records of the last
+// sync are kept for existing entries. When remoteEntries are given, entries whose
+// local file is the same as the remote are recorded as synced.
+func (b *broker) Reindex(remoteEntries []*entry) *syncState {
+	old := b.syncState()
+	s := newSyncState(b.localRoot(), b.BlogID)
+	s.Indexed = true
+	s.IndexedAt = time.Now()
+	s.fresh = true
+	s.LastPull = old.LastPull
+	old.mu.Lock()
+	s.Images = old.Images
+	old.mu.Unlock()
+	s.dirty = true
+
+	remoteMap := map[string]*entry{}
+	for _, re := range remoteEntries {
+		remoteMap[re.EditURL] = re
+	}
+	b.walkLocalEntries(func(path, editURL string) {
+		if editURL == "" {
+			return
+		}
+		es := &entryState{Path: b.relPath(path)}
+		s.Entries[editURL] = es
+		if o := old.get(editURL); o != nil {
+			es.Edited, es.Hash, es.SyncedAt = o.Edited, o.Hash, o.SyncedAt
+		}
+		re, ok := remoteMap[editURL]
+		if !ok || re.LastModified == nil {
+			return
+		}
+		if bb, err := os.ReadFile(path); err == nil && string(bb) == b.fileContent(re, path) {
+			es.Edited, es.Hash, es.SyncedAt = *re.LastModified, contentHash(bb), time.Now()
+		}
+	})
+
+	syncStates.Lock()
+	syncStates.m[b.localRoot()] = s
+	syncStates.Unlock()
+	return s
+}
+
+// changedSinceSync reports whether the local file at path and the remote entry re
+// have been changed since they were synced last time. ok is false if there is
+// no record of the entry.
+func (b *broker) changedSinceSync(path string, re *entry) (localChanged, remoteChanged, ok bool) {
+	es := b.syncState().get(re.EditURL)
+	if es == nil || es.Hash == "" {
+		return false, false, false
+	}
+	bb, err := os.ReadFile(path)
+	localChanged = err != nil || contentHash(bb) != es.Hash
+	remoteChanged = re.LastModified != nil && re.LastModified.After(es.Edited)
+	return localChanged, remoteChanged, true
+}
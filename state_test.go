@@ -0,0 +1,102 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSyncState(t *testing.T) {
+	s, dir := setupFakeBlog(t)
+	editURL := addFakeEntry(t, s, "state", "state")
+
+	blogsync := blogsyncApp(newApp())
+	if _, err := blogsync("pull"); err != nil {
+		t.Fatal(err)
+	}
+
+	loadState := func(t *testing.T) *syncState {
+		t.Helper()
+		st, err := loadSyncState(dir, fakeBlogID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return st
+	}
+
+	t.Run("pull records state", func(t *testing.T) {
+		st := loadState(t)
+		if !st.Indexed {
+			t.Errorf("state should be indexed")
+		}
+		es := st.get(editURL)
+		if es == nil {
+			t.Fatalf("entry is not recorded: %+v", st.Entries)
+		}
+		if es.Path != "entry/state.md" || !es.Edited.Equal(fakeEntryDate) || es.Hash == "" || es.SyncedAt.IsZero() {
+			t.Errorf("unexpected record: %+v", es)
+		}
+	})
+
+	t.Run("index is used and rebuilt when stale", func(t *testing.T) {
+		conf, err := loadConfiguration()
+		if err != nil {
+			t.Fatal(err)
+		}
+		b := newBroker(conf.Get(fakeBlogID), nil)
+		defer saveSyncStates()
+
+		// A file not recorded in the index is not found while the index is valid
+		entryDir := filepath.Join(dir, "entry")
+		unknown := filepath.Join(entryDir, "unknown.md")
+		if err := os.WriteFile(unknown, []byte("---\nEditURL: "+editURL+"2\n---\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(entryDir, fakeEntryDate, fakeEntryDate); err != nil {
+			t.Fatal(err)
+		}
+		if m := b.buildLocalEntryMap(); len(m) != 1 || m[editURL] == "" {
+			t.Errorf("unexpected map: %v", m)
+		}
+
+		// A directory modified after the index may have new files
+		future := time.Now().Add(time.Hour)
+		if err := os.Chtimes(entryDir, future, future); err != nil {
+			t.Fatal(err)
+		}
+		if m := b.buildLocalEntryMap(); len(m) != 2 || m[editURL+"2"] != unknown {
+			t.Errorf("unexpected map: %v", m)
+		}
+
+		renamed := filepath.Join(dir, "entry", "renamed.md")
+		if err := os.Rename(filepath.Join(dir, "entry", "state.md"), renamed); err != nil {
+			t.Fatal(err)
+		}
+		m := b.buildLocalEntryMap()
+		if len(m) != 2 || m[editURL] != renamed || m[editURL+"2"] != unknown {
+			t.Errorf("unexpected map: %v", m)
+		}
+		if es := b.syncState().get(editURL); es.Path != "entry/renamed.md" || es.Hash == "" {
+			t.Errorf("the record of the last sync should be kept: %+v", es)
+		}
+	})
+
+	t.Run("reindex", func(t *testing.T) {
+		if err := os.RemoveAll(filepath.Join(dir, stateDir)); err != nil {
+			t.Fatal(err)
+		}
+		out, err := blogsync("reindex", "--remote")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(out, "2 entries indexed, 1 with sync records") {
+			t.Errorf("unexpected output: %s", out)
+		}
+		es := loadState(t).get(editURL)
+		if es == nil || es.Hash == "" || !es.Edited.Equal(fakeEntryDate) {
+			t.Errorf("unexpected record: %+v", es)
+		}
+	})
+}
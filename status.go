@@ -0,0 +1,181 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+const (
+	statusUnchanged      = "unchanged"
+	statusModified       = "modified"
+	statusRemoteModified = "remote-modified"
+	statusConflict       = "conflict"
+	statusNew            = "new"
+	statusRemoteOnly     = "remote-only"
+	statusDeleted        = "deleted"
+	statusRenamed        = "renamed"
+)
+
+// statusOrder is the order of sections in the text output of status command.
+var statusOrder = []string{
+	statusConflict,
+	statusModified,
+	statusRemoteModified,
+	statusRenamed,
+	statusNew,
+	statusRemoteOnly,
+	statusDeleted,
+}
+
+type entryStatus struct {
+	BlogID     string `json:"blog_id"`
+	Status     string `json:"status"`
+	Path       string `json:"path,omitempty"`
+	RemotePath string `json:"remote_path,omitempty"`
+	EditURL    string `json:"edit_url,omitempty"`
+}
+
+// Status compares the entries in the local root with the remote ones and
+// classifies each of them.
+func (b *broker) Status(ctx context.Context) ([]*entryStatus, error) {
+	localEntryMap := map[string]string{}
+	var newFiles []string
+	b.walkEntryFiles(func(path, editURL string) {
+		if editURL != "" {
+			localEntryMap[editURL] = path
+			return
+		}
+		newFiles = append(newFiles, path)
+	})
+	remoteEntries, err := b.FetchRemoteEntries(ctx, true, true)
+	if err != nil {
+		return nil, err
+	}
+
+	var statuses []*entryStatus
+	add := func(status, path, remotePath, editURL string) {
+		statuses = append(statuses, &entryStatus{
+			BlogID:     b.BlogID,
+			Status:     status,
+			Path:       path,
+			RemotePath: remotePath,
+			EditURL:    editURL,
+		})
+	}
+
+	for _, re := range remoteEntries {
+		remotePath := b.LocalPath(re)
+		localPath, ok := localEntryMap[re.EditURL]
+		if !ok {
+			add(statusRemoteOnly, "", remotePath, re.EditURL)
+			continue
+		}
+		if localPath != remotePath {
+			add(statusRenamed, localPath, remotePath, re.EditURL)
+			continue
+		}
+		status, err := b.compareEntry(localPath, re)
+		if err != nil {
+			return nil, err
+		}
+		add(status, localPath, "", re.EditURL)
+	}
+	for editURL, localPath := range b.deletedEntries(localEntryMap, remoteEntries) {
+		add(statusDeleted, localPath, "", editURL)
+	}
+	for _, path := range newFiles {
+		add(statusNew, path, "", "")
+	}
+
+	sort.SliceStable(statuses, func(i, j int) bool {
+		return statuses[i].sortKey() < statuses[j].sortKey()
+	})
+	return statuses, nil
+}
+
+func (st *entryStatus) sortKey() string {
+	if st.Path != "" {
+		return st.Path
+	}
+	return st.RemotePath
+}
+
+// compareEntry classifies the local file at path against the remote entry re.
+// The direction of a change is decided by the record of the last sync. Without
+// the record, it is decided in the same way as pull and push do, that is, by
+// comparing the modification time of the file with app:edited.
+func (b *broker) compareEntry(path string, re *entry) (string, error) {
+	bb, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	if string(bb) == b.fileContent(re, path) {
+		return statusUnchanged, nil
+	}
+	if localChanged, remoteChanged, ok := b.changedSinceSync(path, re); ok {
+		switch {
+		case localChanged && remoteChanged:
+			return statusConflict, nil
+		case remoteChanged:
+			return statusRemoteModified, nil
+		}
+		return statusModified, nil
+	}
+	localLastModified, err := modTime(path)
+	if err != nil {
+		return "", err
+	}
+	if newerWithAllowance(localLastModified, *re.LastModified) {
+		return statusModified, nil
+	}
+	return statusRemoteModified, nil
+}
+
+func printStatusJSON(w io.Writer, statuses []*entryStatus) error {
+	if statuses == nil {
+		statuses = []*entryStatus{}
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(statuses)
+}
+
+func printStatus(w io.Writer, blogID string, statuses []*entryStatus) {
+	pwd, _ := os.Getwd()
+	rel := func(p string) string {
+		if pwd == "" {
+			return p
+		}
+		if r, err := filepath.Rel(pwd, p); err == nil {
+			return r
+		}
+		return p
+	}
+
+	fmt.Fprintf(w, "On blog %s\n", blogID)
+	changed := false
+	for _, status := range statusOrder {
+		for _, st := range statuses {
+			if st.Status != status {
+				continue
+			}
+			changed = true
+			switch {
+			case st.Path == "":
+				fmt.Fprintf(w, "\t%-16s %s\n", st.Status+":", rel(st.RemotePath))
+			case st.RemotePath == "":
+				fmt.Fprintf(w, "\t%-16s %s\n", st.Status+":", rel(st.Path))
+			default:
+				fmt.Fprintf(w, "\t%-16s %s -> %s\n", st.Status+":", rel(st.Path), rel(st.RemotePath))
+			}
+		}
+	}
+	if !changed {
+		fmt.Fprintln(w, "nothing to sync, local tree is up to date")
+	}
+}
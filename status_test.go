@@ -0,0 +1,114 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/x-motemen/blogsync/atom"
+)
+
+func TestStatus(t *testing.T) {
+	s, dir := setupFakeBlog(t)
+
+	editURLs := map[string]string{}
+	for i, name := range []string{"unchanged", "modified", "remote-modified", "deleted", "renamed"} {
+		d := time.Date(2020, 1, 2, 3, 4, i, 0, jst)
+		e, err := s.AddEntry(&atom.Entry{
+			Title:     name,
+			Content:   atom.Content{Content: name + "\n"},
+			Updated:   &d,
+			Edited:    &d,
+			CustomURL: name,
+		}, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		editURLs[name] = e.Links.Find("edit").Href
+	}
+
+	app := newApp()
+	blogsync := blogsyncApp(app)
+	if _, err := blogsync("pull"); err != nil {
+		t.Fatal(err)
+	}
+
+	entryDir := filepath.Join(dir, "entry")
+	if err := appendFile(filepath.Join(entryDir, "modified.md"), "updated\n"); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Rename(filepath.Join(entryDir, "renamed.md"), filepath.Join(entryDir, "renamed2.md")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(entryDir, "new.md"), []byte("new\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	s.Now = func() time.Time { return time.Now().Add(time.Hour) }
+	s.UpdateEntry(editURLs["remote-modified"], &atom.Entry{
+		Title:   "remote-modified",
+		Content: atom.Content{Content: "updated on web\n"},
+	})
+	s.Delete(editURLs["deleted"])
+	d := time.Date(2022, 1, 1, 0, 0, 0, 0, jst)
+	if _, err := s.AddEntry(&atom.Entry{
+		Title:     "remote-only",
+		Content:   atom.Content{Content: "remote-only\n"},
+		Updated:   &d,
+		CustomURL: "remote-only",
+	}, false); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("json", func(t *testing.T) {
+		out, err := blogsync("status", "--json")
+		if err != nil {
+			t.Fatal(err)
+		}
+		var statuses []*entryStatus
+		if err := json.Unmarshal([]byte(out), &statuses); err != nil {
+			t.Fatal(err)
+		}
+		got := map[string]string{}
+		for _, st := range statuses {
+			p := st.Path
+			if p == "" {
+				p = st.RemotePath
+			}
+			got[filepath.Base(p)] = st.Status
+		}
+		expect := map[string]string{
+			"unchanged.md":       statusUnchanged,
+			"modified.md":        statusModified,
+			"remote-modified.md": statusRemoteModified,
+			"deleted.md":         statusDeleted,
+			"renamed2.md":        statusRenamed,
+			"new.md":             statusNew,
+			"remote-only.md":     statusRemoteOnly,
+		}
+		if fmt.Sprint(got) != fmt.Sprint(expect) {
+			t.Errorf("got: %v\nexpect: %v", got, expect)
+		}
+	})
+
+	t.Run("text", func(t *testing.T) {
+		out, err := blogsync("status")
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, line := range []string{
+			"modified:        entry/modified.md",
+			"renamed:         entry/renamed2.md -> entry/renamed.md",
+		} {
+			if !strings.Contains(out, line) {
+				t.Errorf("output should contain %q, but got:\n%s", line, out)
+			}
+		}
+		if strings.Contains(out, "unchanged.md") {
+			t.Errorf("unchanged entries should not be shown:\n%s", out)
+		}
+	})
+}
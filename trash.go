@@ -0,0 +1,111 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/x-motemen/blogsync/atom"
+)
+
+// trashDir is the directory in the state directory where the entries removed by
+// remove command are kept, so that restore command can post them again.
+const trashDir = "trash"
+
+func (b *broker) trashPath(name string) string {
+	return filepath.Join(b.localRoot(), stateDir, trashDir, name)
+}
+
+// trashEntry saves the remote entry ae and the local file at path of e into the
+// trash directory, and returns the path of the saved entry file. ae is nil if
+// the entry is not found on remote, and the remote entry is written instead of
+// the local file if it does not exist.
+func (b *broker) trashEntry(e *entry, ae *atom.Entry, path string) (string, error) {
+	id := e.entryID()
+	if ae != nil {
+		bb, err := xml.MarshalIndent(ae, "", "  ")
+		if err != nil {
+			return "", err
+		}
+		if err := b.writeFile(b.trashPath(id+".xml"), append([]byte(xml.Header), bb...)); err != nil {
+			return "", err
+		}
+	}
+
+	content, err := os.ReadFile(path)
+	ext := entryFileExt(path)
+	if err != nil {
+		if ae == nil {
+			return "", nil
+		}
+		re, err := entryFromAtom(ae)
+		if err != nil {
+			return "", err
+		}
+		content = []byte(b.fileContent(re, path))
+		ext = extForContentType(re.ContentType)
+	}
+	trashed := b.trashPath(id + ext)
+	return trashed, b.writeFile(trashed, content)
+}
+
+// RestoreEntry posts the entry trashed at path by RemoveEntry again, with the
+// same path, categories and date. The entry gets a new EditURL as the API does
+// not allow to reuse the removed one.
+func (b *broker) RestoreEntry(ctx context.Context, path string) error {
+	if entryFileExt(path) == "" {
+		return fmt.Errorf("%s is not a trashed entry file", path)
+	}
+	e, err := entryFromFile(path)
+	if err != nil {
+		return err
+	}
+	isPage := e.isStaticPage()
+
+	xmlPath := trimEntryExt(path) + ".xml"
+	if bb, err := os.ReadFile(xmlPath); err == nil {
+		ae, err := atom.ParseEntry(bytes.NewReader(bb))
+		if err != nil {
+			return err
+		}
+		re, err := entryFromAtom(ae)
+		if err != nil {
+			return err
+		}
+		if e.URL == nil && !re.IsDraft {
+			e.URL = re.URL
+		}
+		if e.Date == nil {
+			e.Date = re.Date
+		}
+		// The syntax of entries is kept for .md files as in UploadFresh
+		if e.ContentType == contentTypeMarkdown && re.ContentType != "" {
+			e.ContentType = re.ContentType
+		}
+	}
+
+	if e.CustomPath == "" && e.URL != nil {
+		if isPage {
+			e.CustomPath = strings.TrimPrefix(e.URL.Path, "/")
+		} else if _, entryPath := b.blogConfig.extractURLEntryPath(e.URL.Path); entryPath != "" &&
+			!(e.IsDraft && isLikelyGivenPath(entryPath)) {
+			// The temporary URL of drafts is not kept
+			e.CustomPath = entryPath
+		}
+	}
+	e.EditURL = ""
+	e.URL = nil
+	e.PreviewURL = ""
+	e.localPath = ""
+	if err := b.PostEntry(ctx, e, isPage); err != nil {
+		return err
+	}
+	if err := b.removeFile(xmlPath); err != nil {
+		return err
+	}
+	return b.removeFile(path)
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"io"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/x-motemen/blogsync/atom"
+)
+
+func TestRemoveAndRestore(t *testing.T) {
+	s, dir := setupFakeBlog(t)
+	editURL := addFakeAtomEntry(t, s, &atom.Entry{
+		Title:     "trash",
+		Content:   atom.Content{Content: "trash\n"},
+		CustomURL: "trash/me",
+		Category:  []atom.Category{{Term: "foo"}, {Term: "bar"}},
+	})
+	entryID := filepath.Base(editURL)
+
+	app := newApp()
+	app.ErrWriter = io.Discard
+	blogsync := blogsyncApp(app)
+	if _, err := blogsync("pull"); err != nil {
+		t.Fatal(err)
+	}
+	entryFile := filepath.Join(dir, "entry", "trash", "me.md")
+	trashFile := filepath.Join(dir, stateDir, trashDir, entryID+".md")
+
+	t.Log("remove is canceled without confirmation")
+	app.Reader = strings.NewReader("n\n")
+	if _, err := blogsync("remove", entryFile); err != nil {
+		t.Fatal(err)
+	}
+	if s.Entry(editURL) == nil || !exists(entryFile) {
+		t.Fatalf("entry should not be removed")
+	}
+
+	t.Log("remove saves the entry into the trash")
+	app.Reader = strings.NewReader("y\n")
+	if _, err := blogsync("remove", entryFile); err != nil {
+		t.Fatal(err)
+	}
+	if s.Entry(editURL) != nil || exists(entryFile) {
+		t.Fatalf("entry should be removed")
+	}
+	for _, f := range []string{trashFile, filepath.Join(dir, stateDir, trashDir, entryID+".xml")} {
+		if !exists(f) {
+			t.Errorf("%s should be saved", f)
+		}
+	}
+
+	t.Log("restore posts the entry again")
+	if _, err := blogsync("restore", trashFile); err != nil {
+		t.Fatal(err)
+	}
+	entries := s.Entries()
+	if len(entries) != 1 {
+		t.Fatalf("entry should be restored: %+v", entries)
+	}
+	re := entries[0]
+	if re.CustomURL != "trash/me" || len(re.Category) != 2 || re.Category[0].Term != "foo" ||
+		re.Updated == nil || !re.Updated.Equal(fakeEntryDate) || re.Content.Content != "trash\n" {
+		t.Errorf("unexpected restored entry: %+v", re)
+	}
+	restored, err := entryFromFile(entryFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if restored.EditURL != re.Links.Find("edit").Href {
+		t.Errorf("restored file should have the new EditURL: %s", restored.EditURL)
+	}
+	if exists(trashFile) {
+		t.Errorf("trashed file should be removed after restore")
+	}
+}